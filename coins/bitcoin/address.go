@@ -63,3 +63,21 @@ func PubKeyToAddr(publicKey []byte, addrType string, network *chaincfg.Params) (
 		return "", errors.New("address type not supported")
 	}
 }
+
+// AllAddresses returns the legacy, native segwit, nested segwit and taproot addresses of pubKey.
+func AllAddresses(pubKey *btcec.PublicKey, network *chaincfg.Params) (p2pkh, p2wpkh, p2shwpkh, p2tr string, err error) {
+	publicKey := pubKey.SerializeCompressed()
+	if p2pkh, err = PubKeyToAddr(publicKey, LEGACY, network); err != nil {
+		return "", "", "", "", err
+	}
+	if p2wpkh, err = PubKeyToAddr(publicKey, SEGWIT_NATIVE, network); err != nil {
+		return "", "", "", "", err
+	}
+	if p2shwpkh, err = PubKeyToAddr(publicKey, SEGWIT_NESTED, network); err != nil {
+		return "", "", "", "", err
+	}
+	if p2tr, err = PubKeyToAddr(publicKey, TAPROOT, network); err != nil {
+		return "", "", "", "", err
+	}
+	return p2pkh, p2wpkh, p2shwpkh, p2tr, nil
+}
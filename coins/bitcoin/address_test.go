@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/stretchr/testify/assert"
 )
@@ -30,3 +31,18 @@ func TestPubKeyToAddr(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr", p2tr)
 }
+
+func TestAllAddresses(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	publicKey, err := hex.DecodeString("0357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f")
+	assert.Nil(t, err)
+	pubKey, err := btcec.ParsePubKey(publicKey)
+	assert.Nil(t, err)
+
+	p2pkh, p2wpkh, p2shwpkh, p2tr, err := AllAddresses(pubKey, network)
+	assert.Nil(t, err)
+	assert.Equal(t, "mouQtmBWDS7JnT65Grj2tPzdSmGKJgRMhE", p2pkh)
+	assert.Equal(t, "tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc", p2wpkh)
+	assert.Equal(t, "2NF33rckfiQTiE5Guk5ufUdwms8PgmtnEdc", p2shwpkh)
+	assert.Equal(t, "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr", p2tr)
+}
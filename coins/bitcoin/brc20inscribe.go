@@ -0,0 +1,76 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+const BRC20ContentType = "text/plain;charset=utf-8"
+
+var brc20AmountRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// brc20Op is the body of a BRC-20 inscription, the field order is the canonical one.
+type brc20Op struct {
+	P    string `json:"p"`
+	Op   string `json:"op"`
+	Tick string `json:"tick"`
+	Max  string `json:"max,omitempty"`
+	Lim  string `json:"lim,omitempty"`
+	Amt  string `json:"amt,omitempty"`
+}
+
+// NewBRC20Deploy returns the inscription deploying tick with a max supply and an optional mint
+// limit, lim is omitted from the body when empty.
+func NewBRC20Deploy(tick string, max, lim string) (InscriptionData, error) {
+	if err := checkBRC20Amount("max", max); err != nil {
+		return InscriptionData{}, err
+	}
+	if lim != "" {
+		if err := checkBRC20Amount("lim", lim); err != nil {
+			return InscriptionData{}, err
+		}
+	}
+	return newBRC20Inscription(brc20Op{Op: "deploy", Tick: tick, Max: max, Lim: lim})
+}
+
+// NewBRC20Mint returns the inscription minting amt of tick.
+func NewBRC20Mint(tick, amt string) (InscriptionData, error) {
+	if err := checkBRC20Amount("amt", amt); err != nil {
+		return InscriptionData{}, err
+	}
+	return newBRC20Inscription(brc20Op{Op: "mint", Tick: tick, Amt: amt})
+}
+
+// NewBRC20Transfer returns the inscription making amt of tick transferable.
+func NewBRC20Transfer(tick, amt string) (InscriptionData, error) {
+	if err := checkBRC20Amount("amt", amt); err != nil {
+		return InscriptionData{}, err
+	}
+	return newBRC20Inscription(brc20Op{Op: "transfer", Tick: tick, Amt: amt})
+}
+
+func checkBRC20Amount(name, amount string) error {
+	if !brc20AmountRegexp.MatchString(amount) {
+		return fmt.Errorf("brc-20 %s %q is not a decimal number", name, amount)
+	}
+	return nil
+}
+
+func newBRC20Inscription(op brc20Op) (InscriptionData, error) {
+	if len(op.Tick) != 4 {
+		return InscriptionData{}, fmt.Errorf("brc-20 tick %q must be 4 bytes, got %d", op.Tick, len(op.Tick))
+	}
+	op.P = "brc-20"
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(op); err != nil {
+		return InscriptionData{}, err
+	}
+	return InscriptionData{
+		ContentType: BRC20ContentType,
+		Body:        bytes.TrimSuffix(buf.Bytes(), []byte("\n")),
+	}, nil
+}
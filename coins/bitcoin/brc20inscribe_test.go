@@ -0,0 +1,46 @@
+package bitcoin
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewBRC20Deploy(t *testing.T) {
+	data, err := NewBRC20Deploy("ordi", "21000000", "1000")
+	require.NoError(t, err)
+	require.Equal(t, "text/plain;charset=utf-8", data.ContentType)
+	require.Equal(t, `{"p":"brc-20","op":"deploy","tick":"ordi","max":"21000000","lim":"1000"}`, string(data.Body))
+
+	data, err = NewBRC20Deploy("ordi", "21000000", "")
+	require.NoError(t, err)
+	require.Equal(t, `{"p":"brc-20","op":"deploy","tick":"ordi","max":"21000000"}`, string(data.Body))
+
+	_, err = NewBRC20Deploy("ord", "21000000", "1000")
+	require.Error(t, err)
+	_, err = NewBRC20Deploy("ordi", "21e6", "1000")
+	require.Error(t, err)
+	_, err = NewBRC20Deploy("ordi", "21000000", "-1")
+	require.Error(t, err)
+}
+
+func TestNewBRC20MintAndTransfer(t *testing.T) {
+	data, err := NewBRC20Mint("ordi", "1000")
+	require.NoError(t, err)
+	require.Equal(t, `{"p":"brc-20","op":"mint","tick":"ordi","amt":"1000"}`, string(data.Body))
+
+	data, err = NewBRC20Transfer("ordi", "0.5")
+	require.NoError(t, err)
+	require.Equal(t, "text/plain;charset=utf-8", data.ContentType)
+	require.Equal(t, `{"p":"brc-20","op":"transfer","tick":"ordi","amt":"0.5"}`, string(data.Body))
+
+	data, err = NewBRC20Mint("<&>!", "1")
+	require.NoError(t, err)
+	require.Equal(t, `{"p":"brc-20","op":"mint","tick":"<&>!","amt":"1"}`, string(data.Body))
+
+	_, err = NewBRC20Mint("ordinals", "1")
+	require.Error(t, err)
+	_, err = NewBRC20Transfer("ordi", "")
+	require.Error(t, err)
+	_, err = NewBRC20Transfer("ordi", "1.")
+	require.Error(t, err)
+}
@@ -2,23 +2,93 @@ package bitcoin
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
 	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
 type InscriptionData struct {
 	ContentType string `json:"contentType"`
 	Body        []byte `json:"body"`
 	RevealAddr  string `json:"revealAddr"`
+	// CommitFundingVout is the index of the commit output funding the reveal tx of this
+	// inscription. When it is zero for every inscription the outputs follow the list order,
+	// otherwise the values must be a permutation of the inscription indexes.
+	CommitFundingVout int `json:"commitFundingVout"`
+	// Metadata is the raw CBOR metadata of the inscription, pushed under tag 5 in chunks of
+	// at most 520 bytes.
+	Metadata []byte `json:"metadata,omitempty"`
+	// ParentInscriptionId is the id (txid followed by i and the output index) of the parent
+	// inscription, pushed under tag 3. ParentPrevOutput is the output holding the parent,
+	// spent by the reveal tx ahead of the commit output and sent back to RevealAddr ahead of
+	// the inscription output.
+	ParentInscriptionId string      `json:"parentInscriptionId,omitempty"`
+	ParentPrevOutput    *PrevOutput `json:"parentPrevOutput,omitempty"`
+	// DelegateInscriptionId is the id of the inscription whose content is served for this one,
+	// pushed under tag 11. The envelope then has no content type and Body must be empty.
+	DelegateInscriptionId string `json:"delegateInscriptionId,omitempty"`
+	// KeyTweak is a 32 bytes scalar added to the reveal private key of this inscription, so
+	// that every inscription can be committed to and signed by its own key derived from
+	// the same master key.
+	KeyTweak []byte `json:"keyTweak,omitempty"`
+	// RevealPrivateKey is the WIF or hex private key of the reveal tapscript of this inscription,
+	// taking precedence over the RevealInternalKey of the request.
+	RevealPrivateKey string `json:"revealPrivateKey,omitempty"`
+	// RevealPkScript is the script of the reveal output, used instead of RevealAddr when set.
+	RevealPkScript []byte `json:"revealPkScript,omitempty"`
+	// Burn must be set to reveal the inscription to an OP_RETURN script, which destroys it.
+	Burn bool `json:"burn,omitempty"`
+	// ExtraWitness is appended to the reveal witness after the signature, script and control
+	// block, for experiments on networks with custom rules. Standard nodes reject such reveals.
+	ExtraWitness [][]byte `json:"extraWitness,omitempty"`
+	// ContentEncoding is the encoding of Body, such as gzip or br, pushed under tag 9 so that
+	// ord decodes the body before rendering it.
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+	// CompressBody compresses Body with ContentEncoding before it is inscribed, only gzip is
+	// supported. When unset Body must already be encoded.
+	CompressBody bool `json:"compressBody,omitempty"`
+	// Pointer is the offset, within the outputs of the reveal tx, of the sat the inscription
+	// is made on instead of the first sat of its reveal output. It is pushed under tag 2 as a
+	// little endian integer without trailing zero bytes, also when it is 0.
+	Pointer *uint64 `json:"pointer,omitempty"`
+	// RevealOutValue is the value of the reveal output of this inscription, overriding the
+	// RevealOutValue of the request when it is not 0.
+	RevealOutValue int64 `json:"revealOutValue,omitempty"`
+	// RevealAnnex is the taproot annex appended as the last reveal witness element and committed
+	// to by the reveal signature, e.g. to test indexers. It must start with 0x50 and is not
+	// relayed by standard nodes.
+	RevealAnnex []byte `json:"revealAnnex,omitempty"`
+	// ExtraRevealOutputs are added to the reveal tx right after the reveal output of this
+	// inscription and funded by its commit output, e.g. to pay a service fee in the same tx.
+	ExtraRevealOutputs []ExtraRevealOutput `json:"extraRevealOutputs,omitempty"`
+}
+
+// ExtraRevealOutput is an output paying Value to Address in a reveal tx.
+type ExtraRevealOutput struct {
+	Address string `json:"address"`
+	Value   int64  `json:"value"`
 }
 
 type PrevOutput struct {
@@ -28,6 +98,31 @@ type PrevOutput struct {
 	Address    string `json:"address"`
 	PrivateKey string `json:"privateKey"`
 	PublicKey  string `json:"publicKey"`
+	// HasInscription marks an output carrying an inscription. Its value is sent back to its
+	// address by a dedicated commit output, so the inscription keeps its offset and is not
+	// spent as fee or change.
+	HasInscription bool `json:"hasInscription"`
+	// TapMerkleRoot is the 32 bytes merkle root of the script tree committed in a taproot output,
+	// which is then spent by key path with the key tweaked by the root. It is nil for BIP-86
+	// outputs without a script tree.
+	TapMerkleRoot []byte `json:"tapMerkleRoot,omitempty"`
+	// RedeemScript is the m-of-n multisig witness script of a P2WSH output, which is then signed
+	// with PrivateKeys instead of PrivateKey.
+	RedeemScript []byte `json:"redeemScript,omitempty"`
+	// PrivateKeys are WIF keys of RedeemScript, at least m of them in any order.
+	PrivateKeys []string `json:"privateKeys,omitempty"`
+	// PreserveFirstSat makes this output, e.g. one holding a rare sat, the first commit input.
+	// Ordinal theory assigns the sats of the inputs to the outputs first in first out, so its
+	// first sat is the first sat of the first commit output and then of the reveal output it
+	// funds, where the inscription is made. The postage of that inscription must equal Amount.
+	PreserveFirstSat bool `json:"preserveFirstSat,omitempty"`
+}
+
+// ChangeOutput is an address receiving the share Ratio of the change, relative to the sum of
+// the ratios of all change outputs.
+type ChangeOutput struct {
+	Address string  `json:"address"`
+	Ratio   float64 `json:"ratio"`
 }
 
 type InscriptionRequest struct {
@@ -37,7 +132,77 @@ type InscriptionRequest struct {
 	InscriptionDataList    []InscriptionData `json:"inscriptionDataList"`
 	RevealOutValue         int64             `json:"revealOutValue"`
 	ChangeAddress          string            `json:"changeAddress"`
-	MinChangeValue         int64             `json:"minChangeValue"`
+	// ChangePkScript is the script of the change output, used instead of ChangeAddress when set.
+	// It must be a standard script.
+	ChangePkScript []byte `json:"changePkScript,omitempty"`
+	// CommitOpReturn is the data of a zero value OP_RETURN output added to the commit tx before
+	// the change output, e.g. a tag for indexers. It is at most 80 bytes.
+	CommitOpReturn []byte `json:"commitOpReturn,omitempty"`
+	// EnvelopePrefix is the protocol marker pushed after OP_FALSE OP_IF in the envelopes, OrdPrefix
+	// when empty. Envelopes with another prefix are not indexed by ord, see ParseInscriptionWithPrefix.
+	EnvelopePrefix string `json:"envelopePrefix,omitempty"`
+	// ChangeOutputs splits the change between several addresses by ratio instead of sending it
+	// to ChangeAddress, which is still required. A share below the minimum change value or the
+	// dust threshold of its address is dropped and the change split between the others.
+	ChangeOutputs  []ChangeOutput `json:"changeOutputs,omitempty"`
+	MinChangeValue int64          `json:"minChangeValue"`
+	// SacrificeExcessToFee controls what happens to a commit change below MinChangeValue.
+	// When unset or true the excess is paid to the miners as before, when false it is kept
+	// as a change output as long as it is not dust for the change address.
+	SacrificeExcessToFee *bool `json:"sacrificeExcessToFee,omitempty"`
+	// MaxCommitInputs caps the number of inputs of the commit tx, 0 means no limit. When more
+	// inputs are given, only the largest ones covering the commit tx are spent.
+	MaxCommitInputs int `json:"maxCommitInputs"`
+	// FeeRoundingMode decides how fractional virtual sizes are rounded, RoundUp by default.
+	FeeRoundingMode RoundingMode `json:"feeRoundingMode"`
+	// RevealInternalKey is the WIF or hex private key of the reveal tapscript. The key of the
+	// first commit input is used when empty.
+	RevealInternalKey string `json:"revealInternalKey"`
+	// FeeBufferPercent pads every computed commit and reveal fee by this percentage to absorb
+	// the variance of the signature sizes, 0 means no padding.
+	FeeBufferPercent int `json:"feeBufferPercent"`
+	// ExactRevealPostage leaves the reveal fees out of FeeBufferPercent, so the commit outputs
+	// carry exactly the postage plus the reveal fee of the signed reveal txs, whose size the
+	// reveal templates already have.
+	ExactRevealPostage bool `json:"exactRevealPostage"`
+	// SigHashCache, when set, is shared by the builds of the request to reuse the sighash
+	// midstates of reveal txs which did not change.
+	SigHashCache *SigHashCache `json:"-"`
+	// DefaultPostageByType is the reveal output value of inscriptions by content type when
+	// RevealOutValue is 0. Keys are media types such as image/png, or a top level type followed
+	// by a slash such as image/ for all of its subtypes. Unmatched inscriptions get 546.
+	DefaultPostageByType map[string]int64 `json:"defaultPostageByType,omitempty"`
+	// DisableRBF makes every commit and reveal input final instead of signaling replaceability.
+	DisableRBF bool `json:"disableRBF"`
+	// SingleRevealTx reveals all inscriptions in one tx spending every commit output instead
+	// of one reveal tx per inscription. The commit tx still creates one output per inscription.
+	SingleRevealTx bool `json:"singleRevealTx"`
+	// AggregateReveal commits to all inscriptions in one commit output carrying all of their
+	// postage, spent by a single reveal tx input whose tapscript holds every envelope. The
+	// inscriptions after the first get a pointer to their own reveal output, so they can not
+	// set one themselves nor have a parent.
+	AggregateReveal bool `json:"aggregateReveal"`
+	// AllowDust lets reveal outputs hold less than the dust threshold of their script, e.g. for
+	// a relay accepting them. SimulateAcceptance still reports them as not standard.
+	AllowDust bool `json:"allowDust"`
+	// Deterministic signs the reveal inputs with the BIP-340 nonce derivation and an all zero
+	// aux rand, so that the same request always gives the same reveal txs, also with other
+	// BIP-340 signers. Without it the nonces come from RFC6979, which is deterministic as well
+	// but specific to btcec. Fresh aux randomness protects the key against fault and
+	// side-channel attacks on the nonce derivation, which a zero aux rand gives up.
+	Deterministic bool `json:"deterministic"`
+	// SelectInputs spends only the smallest set of CommitTxPrevOutputList, picked largest first,
+	// which covers the TargetAmount of the commit tx, instead of all of them. Inputs carrying an
+	// inscription are always spent.
+	SelectInputs bool `json:"selectInputs"`
+	// CommitSigHashType is the sighash type of the commit input signatures, such as
+	// SigHashAll|SigHashAnyOneCanPay to let inputs be added later to bump the fee. When unset
+	// taproot inputs are signed with SigHashDefault and the others with SigHashAll.
+	CommitSigHashType txscript.SigHashType `json:"commitSigHashType"`
+	// IgnoreAncestorLimit builds requests whose commit tx has more reveal children than the
+	// mempool accepts while it is unconfirmed, see WouldExceedAncestorLimit. The extra reveal
+	// txs must then be broadcast once the commit tx is confirmed.
+	IgnoreAncestorLimit bool `json:"ignoreAncestorLimit"`
 }
 
 type inscriptionTxCtxData struct {
@@ -47,28 +212,103 @@ type inscriptionTxCtxData struct {
 	CommitTxAddressPkScript []byte
 	ControlBlockWitness     []byte
 	RevealTxPrevOutput      *wire.TxOut
+	CommitTxOutIndex        uint32
+	TapMerkleRoot           []byte
+	ParentPrivateKey        *btcec.PrivateKey
+	ParentOutPoint          *wire.OutPoint
+	ParentPrevOutput        *wire.TxOut
+	ExtraWitness            [][]byte
+	Annex                   []byte
 }
 
+// InscriptionBuilder holds the signed commit and reveal txs of an InscriptionRequest.
+// Once built, its read accessors (GetCommitTxHex, GetRevealTxHexList, CalculateFee,
+// RevealSigHashes, InscriptionIDs, TotalVSize...) do not modify it and are safe for concurrent
+// use. ApplyRevealSignatures rewrites the reveal witnesses and must not run concurrently with them.
 type InscriptionBuilder struct {
 	Network                   *chaincfg.Params
 	CommitTxPrevOutputFetcher *txscript.MultiPrevOutFetcher
 	CommitTxPrivateKeyList    []*btcec.PrivateKey
+	// CommitTxMultisigKeyList holds the keys of the P2WSH multisig commit inputs, nil for the
+	// other inputs, whose CommitTxPrivateKeyList entry is then the first of them.
+	CommitTxMultisigKeyList   [][]*btcec.PrivateKey
 	InscriptionTxCtxDataList  []*inscriptionTxCtxData
 	RevealTxPrevOutputFetcher *txscript.MultiPrevOutFetcher
 	CommitTxPrevOutputList    []*PrevOutput
+	InscriptionDataList       []InscriptionData
 	RevealTx                  []*wire.MsgTx
 	CommitTx                  *wire.MsgTx
 	MustCommitTxFee           int64
 	MustRevealTxFees          []int64
 	CommitAddrs               []string
+	FeeRoundingMode           RoundingMode
+	FeeBufferPercent          int
+	ExactRevealPostage        bool
+	SigHashCache              *SigHashCache
+	RevealFeeRates            []int64
+	DisableRBF                bool
+	SingleRevealTx            bool
+	AggregateReveal           bool
+	AllowDust                 bool
+	Deterministic             bool
+	CommitOpReturn            []byte
+	ChangeOutputs             []ChangeOutput
+	CommitSigHashType         txscript.SigHashType
+	warnings                  []string
+	fundingShortfall          int64
+}
+
+// ConsolidationSuggestion is returned as the error of NewInscriptionTool when the commit tx
+// would need more than MaxCommitInputs inputs. It describes a consolidation tx merging
+// the inputs the commit tx needs into the change address, which should be broadcast before
+// inscribing.
+type ConsolidationSuggestion struct {
+	Inputs          []*PrevOutput `json:"inputs"`
+	Address         string        `json:"address"`
+	Amount          int64         `json:"amount"`
+	Fee             int64         `json:"fee"`
+	MaxCommitInputs int           `json:"maxCommitInputs"`
+}
+
+func (s *ConsolidationSuggestion) Error() string {
+	return fmt.Sprintf("commit tx needs %d inputs, more than the maximum %d, consolidate them into %s first", len(s.Inputs), s.MaxCommitInputs, s.Address)
 }
 
+var (
+	// ErrInsufficientBalance is returned when the commit inputs cannot pay for the reveal
+	// outputs and the commit fee.
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	// ErrRevealWeightExceeded is returned when a reveal tx is heavier than MaxStandardTxWeight.
+	ErrRevealWeightExceeded = errors.New("reveal transaction weight greater than MAX_STANDARD_TX_WEIGHT")
+	// ErrInvalidChangeAddress is returned when the change address or script cannot be paid to.
+	ErrInvalidChangeAddress = errors.New("invalid change address")
+	// ErrFeeRateOutOfRange is returned when a commit or reveal fee rate is not between
+	// MinRelayFeeRate and MaxFeeRate.
+	ErrFeeRateOutOfRange = errors.New("fee rate out of range")
+	// ErrRevealNotLinked is returned when a reveal tx does not spend the commit tx yet, because
+	// the builder failed or was not signed before its commit txid was known.
+	ErrRevealNotLinked = errors.New("reveal tx not linked to the commit tx")
+	// ErrAncestorLimitExceeded is returned when the commit tx would have more unconfirmed reveal
+	// children than MaxPackageDescendants allows, unless IgnoreAncestorLimit is set.
+	ErrAncestorLimitExceeded = errors.New("too many reveal txs for the mempool descendant limit")
+)
+
 type InscribeTxs struct {
 	CommitTx     string   `json:"commitTx"`
 	RevealTxs    []string `json:"revealTxs"`
 	CommitTxFee  int64    `json:"commitTxFee"`
 	RevealTxFees []int64  `json:"revealTxFees"`
 	CommitAddrs  []string `json:"commitAddrs"`
+	// CommitTxId and RevealTxIds are the witness stripped txids, the hash of the full
+	// serialization (wtxid) must not be used to track the txs.
+	CommitTxId  string   `json:"commitTxId"`
+	RevealTxIds []string `json:"revealTxIds"`
+	// TotalInputValue is the value of the commit inputs funding the inscriptions, which is
+	// TotalPostage plus the commit and reveal fees plus ChangeValue. Inscribed commit inputs and
+	// parents are sent back as they are and not counted.
+	TotalInputValue int64 `json:"totalInputValue"`
+	TotalPostage    int64 `json:"totalPostage"`
+	ChangeValue     int64 `json:"changeValue"`
 }
 
 type InscribeForMPCRes struct {
@@ -78,6 +318,10 @@ type InscribeForMPCRes struct {
 	CommitTxFee  int64    `json:"commitTxFee"`
 	RevealTxFees []int64  `json:"revealTxFees"`
 	CommitAddrs  []string `json:"commitAddrs"`
+	// CommitTxId is the txid the reveal txs spend, the one of the signed commit tx when its hash
+	// is given, and RevealTxIds are the txids of the reveal txs.
+	CommitTxId  string   `json:"commitTxId"`
+	RevealTxIds []string `json:"revealTxIds"`
 }
 
 const (
@@ -86,384 +330,3217 @@ const (
 	DefaultRevealOutValue = int64(546)
 	DefaultMinChangeValue = int64(546)
 
+	DustRelayFeeRate = int64(3)
+	MinRelayFeeRate  = int64(1)
+	// MaxFeeRate is the highest commit or reveal fee rate in sat/vB accepted in a request, to
+	// catch mistyped rates before they are paid.
+	MaxFeeRate = int64(10000)
+
+	// MainNetMinFeeRate is the default fee rate on mainnet, one sat/vB above the relay minimum
+	// so the txs are not stuck at the bottom of the mempool.
+	MainNetMinFeeRate = int64(2)
+	// DevNetDustValue is the default dust of the regtest and simnet networks, the dust of a
+	// taproot output.
+	DevNetDustValue = int64(330)
+
 	MaxStandardTxWeight = 4000000 / 10
-	WitnessScaleFactor  = 4
+	// MaxPackageDescendants is bitcoin core's default limit of in-mempool descendants of a tx,
+	// itself included, so a commit tx can have at most 24 unconfirmed reveal txs.
+	MaxPackageDescendants = 25
+	WitnessScaleFactor    = 4
 
 	OrdPrefix = "ord"
 )
 
+// OrdTag is the tag pushed before a field of the inscription envelope, as defined by ord.
+type OrdTag byte
+
+const (
+	TagContentType     OrdTag = 1
+	TagPointer         OrdTag = 2
+	TagParent          OrdTag = 3
+	TagMetadata        OrdTag = 5
+	TagMetaprotocol    OrdTag = 7
+	TagContentEncoding OrdTag = 9
+	TagDelegate        OrdTag = 11
+)
+
+// RoundingMode is the way a transaction weight is rounded to virtual bytes when computing fees.
+type RoundingMode int
+
+const (
+	RoundUp RoundingMode = iota
+	RoundDown
+	RoundNearest
+)
+
+// NetworkDefaults returns the fee rate in sat/vB and the minimum change value used when the
+// request leaves them unset.
+func NetworkDefaults(network *chaincfg.Params) (minRate, dust int64) {
+	switch network.Net {
+	case chaincfg.MainNetParams.Net:
+		return MainNetMinFeeRate, DefaultMinChangeValue
+	case chaincfg.RegressionNetParams.Net, chaincfg.SimNetParams.Net:
+		return MinRelayFeeRate, DevNetDustValue
+	default:
+		return MinRelayFeeRate, DefaultMinChangeValue
+	}
+}
+
+// normalizeFeeRates resolves the commit fee rate and the reveal fee rate of every inscription
+// of request in sat/vB. A rate set in the request takes precedence over the network default,
+// which is used when the rate is 0, and must be between MinRelayFeeRate and MaxFeeRate.
+func normalizeFeeRates(network *chaincfg.Params, request *InscriptionRequest) (commitRate int64, revealRates []int64, err error) {
+	minRate, _ := NetworkDefaults(network)
+	resolve := func(name string, rate int64) (int64, error) {
+		if rate == 0 {
+			return minRate, nil
+		}
+		if rate < MinRelayFeeRate || rate > MaxFeeRate {
+			return 0, fmt.Errorf("%w: %s fee rate %d sat/vB is not between %d and %d", ErrFeeRateOutOfRange, name, rate, MinRelayFeeRate, MaxFeeRate)
+		}
+		return rate, nil
+	}
+	commitRate, err = resolve("commit", request.CommitFeeRate)
+	if err != nil {
+		return 0, nil, err
+	}
+	revealRate, err := resolve("reveal", request.RevealFeeRate)
+	if err != nil {
+		return 0, nil, err
+	}
+	revealRates = make([]int64, len(request.InscriptionDataList))
+	for i := range revealRates {
+		revealRates[i] = revealRate
+	}
+	return commitRate, revealRates, nil
+}
+
+// computeFee returns the fee of a tx of the given weight at feeRate sat/vB.
+func computeFee(weight, feeRate int64, mode RoundingMode) int64 {
+	var vSize int64
+	switch mode {
+	case RoundDown:
+		vSize = weight / WitnessScaleFactor
+	case RoundNearest:
+		vSize = (weight + WitnessScaleFactor/2) / WitnessScaleFactor
+	default:
+		vSize = (weight + WitnessScaleFactor - 1) / WitnessScaleFactor
+	}
+	return vSize * feeRate
+}
+
+// applyFeeBuffer pads fee by percent, rounding the padding up.
+func applyFeeBuffer(fee int64, percent int) int64 {
+	if percent <= 0 {
+		return fee
+	}
+	return fee + (fee*int64(percent)+99)/100
+}
+
+// inputSequence returns the sequence of commit and reveal inputs, final when disableRBF is set
+// or else signaling replaceability.
+func inputSequence(disableRBF bool) uint32 {
+	if disableRBF {
+		return wire.MaxTxInSequenceNum
+	}
+	return DefaultSequenceNum
+}
+
+// revealTxWeight returns the weight of the unsigned reveal tx once witness is attached to its
+// first input, the other inputs counting with the witness they already carry.
+func revealTxWeight(tx *wire.MsgTx, witness wire.TxWitness) int64 {
+	// marker and flag bytes are only serialized once the tx has a witness
+	weight := int64(tx.SerializeSizeStripped()*WitnessScaleFactor + 2 + witness.SerializeSize())
+	for _, in := range tx.TxIn[1:] {
+		weight += int64(in.Witness.SerializeSize())
+	}
+	return weight
+}
+
 func NewInscriptionTool(network *chaincfg.Params, request *InscriptionRequest) (*InscriptionBuilder, error) {
+	return newInscriptionTool(context.Background(), network, request)
+}
+
+// NewInscriptionToolWithKeys is NewInscriptionTool for commit inputs whose private keys are the
+// 32 raw bytes of keys, one per input of CommitTxPrevOutputList, instead of the WIF of their
+// PrivateKey. The keys are used compressed and the first one is the default reveal key, like the
+// key of the first input is otherwise. Multisig inputs must keep their PrivateKeys.
+func NewInscriptionToolWithKeys(network *chaincfg.Params, request *InscriptionRequest, keys [][]byte) (*InscriptionBuilder, error) {
+	if len(keys) != len(request.CommitTxPrevOutputList) {
+		return nil, fmt.Errorf("got %d keys for %d commit inputs", len(keys), len(request.CommitTxPrevOutputList))
+	}
+	withKeys := *request
+	withKeys.CommitTxPrevOutputList = make([]*PrevOutput, len(keys))
+	for i, key := range keys {
+		if len(request.CommitTxPrevOutputList[i].RedeemScript) > 0 {
+			return nil, fmt.Errorf("commit input %d is a multisig input, whose keys are its PrivateKeys", i)
+		}
+		if len(key) != btcec.PrivKeyBytesLen {
+			return nil, fmt.Errorf("key of commit input %d is %d bytes, not %d", i, len(key), btcec.PrivKeyBytesLen)
+		}
+		privateKey, _ := btcec.PrivKeyFromBytes(key)
+		wif, err := btcutil.NewWIF(privateKey, network, true)
+		if err != nil {
+			return nil, err
+		}
+		prevOutput := *request.CommitTxPrevOutputList[i]
+		prevOutput.PrivateKey = wif.String()
+		withKeys.CommitTxPrevOutputList[i] = &prevOutput
+	}
+	return NewInscriptionTool(network, &withKeys)
+}
+
+func newInscriptionTool(ctx context.Context, network *chaincfg.Params, request *InscriptionRequest) (*InscriptionBuilder, error) {
+	request = inscribedInputsFirst(request)
+	tool, err := newInscriptionBuilder(network, request)
+	if err != nil {
+		return nil, err
+	}
+	if err := tool.initTool(ctx, network, request); err != nil {
+		return tool, err
+	}
+	if tool.WouldExceedAncestorLimit() && !request.IgnoreAncestorLimit {
+		return tool, fmt.Errorf("%w: %d reveal txs spend the commit tx but only %d can be relayed before it confirms, split the inscriptions over several requests or set IgnoreAncestorLimit",
+			ErrAncestorLimitExceeded, len(tool.RevealTx), MaxPackageDescendants-1)
+	}
+	return tool, nil
+}
+
+// ValidateFunding checks, before anything is signed, that the commit inputs cover the reveal
+// outputs plus the estimated commit fee. It returns the missing amount in satoshis, or 0 when
+// the request is sufficiently funded.
+func ValidateFunding(network *chaincfg.Params, request *InscriptionRequest) (int64, error) {
+	request = inscribedInputsFirst(request)
+	builder, err := newInscriptionBuilder(network, request)
+	if err != nil {
+		return 0, err
+	}
+	commitFeeRate, minChangeValue, totalRevealPrevOutputValue, err := builder.buildReveals(context.Background(), network, request)
+	if err != nil {
+		return 0, err
+	}
+	changePkScript, err := requestChangePkScript(network, request)
+	if err != nil {
+		return 0, err
+	}
+	err = builder.buildRequestCommitTx(request, changePkScript, totalRevealPrevOutputValue, commitFeeRate, minChangeValue)
+	if err != nil && builder.fundingShortfall > 0 {
+		return builder.fundingShortfall, nil
+	}
+	return 0, err
+}
+
+func newInscriptionBuilder(network *chaincfg.Params, request *InscriptionRequest) (*InscriptionBuilder, error) {
 	var commitTxPrivateKeyList []*btcec.PrivateKey
-	for _, prevOutput := range request.CommitTxPrevOutputList {
+	commitTxMultisigKeyList := make([][]*btcec.PrivateKey, len(request.CommitTxPrevOutputList))
+	for i, prevOutput := range request.CommitTxPrevOutputList {
+		if len(prevOutput.RedeemScript) > 0 {
+			keys, err := multisigPrevOutputKeys(i, prevOutput, network)
+			if err != nil {
+				return nil, err
+			}
+			commitTxPrivateKeyList = append(commitTxPrivateKeyList, keys[0])
+			commitTxMultisigKeyList[i] = keys
+			continue
+		}
 		privateKeyWif, err := btcutil.DecodeWIF(prevOutput.PrivateKey)
 		if err != nil {
 			return nil, err
 		}
+		if !privateKeyWif.IsForNet(network) {
+			return nil, fmt.Errorf("private key of commit input %d is not for network %s", i, network.Name)
+		}
+		if err := checkPrevOutputKey(i, prevOutput, privateKeyWif.PrivKey, network); err != nil {
+			return nil, err
+		}
 		commitTxPrivateKeyList = append(commitTxPrivateKeyList, privateKeyWif.PrivKey)
 	}
-	tool := &InscriptionBuilder{
+	builder := requestBuilder(network, request, commitTxPrivateKeyList)
+	builder.CommitTxMultisigKeyList = commitTxMultisigKeyList
+	return builder, nil
+}
+
+// multisigPrevOutputKeys decodes the PrivateKeys of the P2WSH multisig commit input prevOutput,
+// checking that its address pays to its RedeemScript and that they are enough keys of it.
+func multisigPrevOutputKeys(index int, prevOutput *PrevOutput, network *chaincfg.Params) ([]*btcec.PrivateKey, error) {
+	class, pubKeys, required, err := txscript.ExtractPkScriptAddrs(prevOutput.RedeemScript, network)
+	if err != nil {
+		return nil, err
+	}
+	if class != txscript.MultiSigTy {
+		return nil, fmt.Errorf("redeem script of commit input %d is not a multisig script", index)
+	}
+	scriptHash := sha256.Sum256(prevOutput.RedeemScript)
+	address, err := btcutil.NewAddressWitnessScriptHash(scriptHash[:], network)
+	if err != nil {
+		return nil, err
+	}
+	if address.EncodeAddress() != prevOutput.Address {
+		return nil, fmt.Errorf("address %s of commit input %d is not the p2wsh address of its redeem script", prevOutput.Address, index)
+	}
+	keys := make([]*btcec.PrivateKey, 0, len(prevOutput.PrivateKeys))
+	for k, key := range prevOutput.PrivateKeys {
+		privateKeyWif, err := btcutil.DecodeWIF(key)
+		if err != nil {
+			return nil, fmt.Errorf("private key %d of commit input %d error: %w", k, index, err)
+		}
+		if !privateKeyWif.IsForNet(network) {
+			return nil, fmt.Errorf("private key %d of commit input %d is not for network %s", k, index, network.Name)
+		}
+		pubKey := privateKeyWif.PrivKey.PubKey().SerializeCompressed()
+		found := false
+		for _, scriptPubKey := range pubKeys {
+			found = found || bytes.Equal(scriptPubKey.ScriptAddress(), pubKey)
+		}
+		if !found {
+			return nil, fmt.Errorf("private key %d of commit input %d is not a key of its redeem script", k, index)
+		}
+		keys = append(keys, privateKeyWif.PrivKey)
+	}
+	if len(keys) < required {
+		return nil, fmt.Errorf("commit input %d has %d keys of its %d-of-%d redeem script", index, len(keys), required, len(pubKeys))
+	}
+	return keys, nil
+}
+
+// signMultisigInput signs the P2WSH multisig input index of tx with required keys among keys,
+// in the order of the public keys of witnessScript and after the dummy element popped by
+// OP_CHECKMULTISIG.
+func signMultisigInput(tx *wire.MsgTx, index int, txSigHashes *txscript.TxSigHashes, amount int64,
+	witnessScript []byte, keys []*btcec.PrivateKey, hashType txscript.SigHashType) error {
+	_, pubKeys, required, err := txscript.ExtractPkScriptAddrs(witnessScript, &chaincfg.MainNetParams)
+	if err != nil {
+		return err
+	}
+	if hashType == 0 {
+		hashType = txscript.SigHashAll
+	}
+	witness := wire.TxWitness{nil}
+	for _, pubKey := range pubKeys {
+		if len(witness)-1 == required {
+			break
+		}
+		for _, key := range keys {
+			if !bytes.Equal(pubKey.ScriptAddress(), key.PubKey().SerializeCompressed()) {
+				continue
+			}
+			signature, err := txscript.RawTxInWitnessSignature(tx, txSigHashes, index, amount, witnessScript, hashType, key)
+			if err != nil {
+				return err
+			}
+			witness = append(witness, signature)
+			break
+		}
+	}
+	if len(witness)-1 < required {
+		return fmt.Errorf("input %d has %d of the %d signatures of its multisig script", index, len(witness)-1, required)
+	}
+	tx.TxIn[index].Witness = append(witness, witnessScript)
+	return nil
+}
+
+// requestBuilder returns an empty builder of request whose commit inputs are signed by
+// commitTxPrivateKeyList.
+func requestBuilder(network *chaincfg.Params, request *InscriptionRequest, commitTxPrivateKeyList []*btcec.PrivateKey) *InscriptionBuilder {
+	return &InscriptionBuilder{
 		Network:                   network,
 		CommitTxPrevOutputFetcher: txscript.NewMultiPrevOutFetcher(nil),
 		CommitTxPrivateKeyList:    commitTxPrivateKeyList,
 		InscriptionTxCtxDataList:  make([]*inscriptionTxCtxData, len(request.InscriptionDataList)),
 		RevealTxPrevOutputFetcher: txscript.NewMultiPrevOutFetcher(nil),
 		CommitTxPrevOutputList:    request.CommitTxPrevOutputList,
+		InscriptionDataList:       request.InscriptionDataList,
+		FeeRoundingMode:           request.FeeRoundingMode,
+		FeeBufferPercent:          request.FeeBufferPercent,
+		ExactRevealPostage:        request.ExactRevealPostage,
+		SigHashCache:              request.SigHashCache,
+		DisableRBF:                request.DisableRBF,
+		SingleRevealTx:            request.SingleRevealTx,
+		AggregateReveal:           request.AggregateReveal,
+		AllowDust:                 request.AllowDust,
+		Deterministic:             request.Deterministic,
+		CommitOpReturn:            request.CommitOpReturn,
+		ChangeOutputs:             request.ChangeOutputs,
+		CommitSigHashType:         request.CommitSigHashType,
 	}
-	return tool, tool.initTool(network, request)
 }
 
-func (builder *InscriptionBuilder) initTool(network *chaincfg.Params, request *InscriptionRequest) error {
-	destinations := make([]string, len(request.InscriptionDataList))
-	revealOutValue := DefaultRevealOutValue
-	if request.RevealOutValue > 0 {
-		revealOutValue = request.RevealOutValue
+// EstimateInscribeFees returns the commit fee, the reveal fees and their total for request
+// without its private keys, signing the txs with a throwaway key only to size them. Commit inputs
+// only need an address, inputs without a txid get a dummy one, and a single input from the
+// change address is assumed when there are none. P2WSH multisig inputs also need their RedeemScript,
+// which is sized with throwaway keys of the same threshold. The commit fee is the one of a commit tx
+// with a change output, and the total does not include the postage.
+func EstimateInscribeFees(network *chaincfg.Params, request *InscriptionRequest) (commitFee int64, revealFees []int64, total int64, err error) {
+	dummyKey, _ := btcec.PrivKeyFromBytes(chainhash.HashB([]byte("inscribe fee estimation")))
+	estimateRequest := *request
+	if estimateRequest.RevealInternalKey == "" {
+		estimateRequest.RevealInternalKey = hex.EncodeToString(dummyKey.Serialize())
 	}
-	minChangeValue := DefaultMinChangeValue
-	if request.MinChangeValue > 0 {
-		minChangeValue = request.MinChangeValue
+	prevOutputList := request.CommitTxPrevOutputList
+	if len(prevOutputList) == 0 {
+		prevOutputList = []*PrevOutput{{Address: request.ChangeAddress}}
 	}
-	for i := 0; i < len(request.InscriptionDataList); i++ {
-		inscriptionTxCtxData, err := newInscriptionTxCtxData(network, request, i)
-		if err != nil {
-			return err
+	estimateRequest.CommitTxPrevOutputList = make([]*PrevOutput, len(prevOutputList))
+	keys := make([]*btcec.PrivateKey, len(prevOutputList))
+	multisigKeys := make(map[*PrevOutput][]*btcec.PrivateKey)
+	for i, prevOutput := range prevOutputList {
+		estimateOutput := *prevOutput
+		if estimateOutput.TxId == "" {
+			estimateOutput.TxId = chainhash.HashH([]byte(fmt.Sprintf("dummy input %d", i))).String()
 		}
-		builder.InscriptionTxCtxDataList[i] = inscriptionTxCtxData
-		destinations[i] = request.InscriptionDataList[i].RevealAddr
+		if len(estimateOutput.RedeemScript) > 0 {
+			if multisigKeys[&estimateOutput], err = estimateMultisigPrevOutput(i, &estimateOutput, network); err != nil {
+				return 0, nil, 0, err
+			}
+		}
+		estimateRequest.CommitTxPrevOutputList[i] = &estimateOutput
+		keys[i] = dummyKey
 	}
-	totalRevealPrevOutputValue, err := builder.buildEmptyRevealTx(destinations, revealOutValue, request.RevealFeeRate)
-	if err != nil {
-		return err
+	estimate := inscribedInputsFirst(&estimateRequest)
+	builder := requestBuilder(network, estimate, keys)
+	builder.CommitTxMultisigKeyList = make([][]*btcec.PrivateKey, len(estimate.CommitTxPrevOutputList))
+	for i, prevOutput := range estimate.CommitTxPrevOutputList {
+		builder.CommitTxMultisigKeyList[i] = multisigKeys[prevOutput]
 	}
-	err = builder.buildCommitTx(request.CommitTxPrevOutputList, request.ChangeAddress, totalRevealPrevOutputValue, request.CommitFeeRate, minChangeValue)
+	commitFeeRate, _, totalRevealPrevOutputValue, err := builder.buildReveals(context.Background(), network, estimate)
 	if err != nil {
-		return err
+		return 0, nil, 0, err
 	}
-	err = builder.signCommitTx()
-	if err != nil {
-		return errors.New("sign commit tx error")
+	// fund the commit tx generously so that it keeps its change output, the amounts do not
+	// change the size of the txs
+	for _, prevOutput := range estimate.CommitTxPrevOutputList {
+		if !prevOutput.HasInscription {
+			prevOutput.Amount += totalRevealPrevOutputValue + btcutil.SatoshiPerBitcoin
+			break
+		}
 	}
-	err = builder.completeRevealTx()
+	changePkScript, err := requestChangePkScript(network, estimate)
 	if err != nil {
-		return err
+		return 0, nil, 0, err
 	}
-	return nil
+	if err := builder.buildCommitTx(estimate.CommitTxPrevOutputList, changePkScript, totalRevealPrevOutputValue, commitFeeRate, 0, true); err != nil {
+		return 0, nil, 0, err
+	}
+	commitFee = builder.CommitTotalInput() - builder.CommitTotalOutput()
+	total = commitFee
+	for _, fee := range builder.MustRevealTxFees {
+		total += fee
+	}
+	return commitFee, builder.MustRevealTxFees, total, nil
 }
 
-func newInscriptionTxCtxData(network *chaincfg.Params, inscriptionRequest *InscriptionRequest, indexOfInscriptionDataList int) (*inscriptionTxCtxData, error) {
-	privateKeyWif, err := btcutil.DecodeWIF(inscriptionRequest.CommitTxPrevOutputList[0].PrivateKey)
+// estimateMultisigPrevOutput replaces the RedeemScript of the P2WSH multisig commit input
+// prevOutput by a script of as many throwaway keys with the same threshold, and its address by
+// the p2wsh address of that script, so that it is signed to the same size without its keys. It
+// returns the throwaway keys signing it.
+func estimateMultisigPrevOutput(index int, prevOutput *PrevOutput, network *chaincfg.Params) ([]*btcec.PrivateKey, error) {
+	class, scriptPubKeys, required, err := txscript.ExtractPkScriptAddrs(prevOutput.RedeemScript, network)
 	if err != nil {
 		return nil, err
 	}
-	privateKey := privateKeyWif.PrivKey
-
-	inscriptionBuilder := txscript.NewScriptBuilder().
-		AddData(schnorr.SerializePubKey(privateKey.PubKey())).
-		AddOp(txscript.OP_CHECKSIG).
-		AddOp(txscript.OP_FALSE).
-		AddOp(txscript.OP_IF).
-		AddData([]byte(OrdPrefix)).
-		AddOp(txscript.OP_DATA_1).
-		AddOp(txscript.OP_DATA_1).
-		AddData([]byte(inscriptionRequest.InscriptionDataList[indexOfInscriptionDataList].ContentType)).
-		AddOp(txscript.OP_0)
-	maxChunkSize := 520
-	// use taproot to skip txscript.MaxScriptSize 10000
-	bodySize := len(inscriptionRequest.InscriptionDataList[indexOfInscriptionDataList].Body)
-	for i := 0; i < bodySize; i += maxChunkSize {
-		end := i + maxChunkSize
-		if end > bodySize {
-			end = bodySize
+	if class != txscript.MultiSigTy {
+		return nil, fmt.Errorf("redeem script of commit input %d is not a multisig script", index)
+	}
+	keys := make([]*btcec.PrivateKey, len(scriptPubKeys))
+	pubKeys := make([]*btcutil.AddressPubKey, len(scriptPubKeys))
+	for k := range keys {
+		keys[k], _ = btcec.PrivKeyFromBytes(chainhash.HashB([]byte(fmt.Sprintf("inscribe fee estimation multisig key %d", k))))
+		if pubKeys[k], err = btcutil.NewAddressPubKey(keys[k].PubKey().SerializeCompressed(), network); err != nil {
+			return nil, err
 		}
-
-		inscriptionBuilder.AddFullData(inscriptionRequest.InscriptionDataList[indexOfInscriptionDataList].Body[i:end])
 	}
-	inscriptionScript, err := inscriptionBuilder.Script()
+	redeemScript, err := txscript.MultiSigScript(pubKeys, required)
 	if err != nil {
 		return nil, err
 	}
-	inscriptionScript = append(inscriptionScript, txscript.OP_ENDIF)
-
-	proof := &txscript.TapscriptProof{
-		TapLeaf:  txscript.NewBaseTapLeaf(schnorr.SerializePubKey(privateKey.PubKey())),
-		RootNode: txscript.NewBaseTapLeaf(inscriptionScript),
+	scriptHash := sha256.Sum256(redeemScript)
+	address, err := btcutil.NewAddressWitnessScriptHash(scriptHash[:], network)
+	if err != nil {
+		return nil, err
 	}
+	prevOutput.RedeemScript = redeemScript
+	prevOutput.Address = address.EncodeAddress()
+	prevOutput.PrivateKeys = nil
+	return keys[:required], nil
+}
 
-	controlBlock := proof.ToControlBlock(privateKey.PubKey())
-	controlBlockWitness, err := controlBlock.ToBytes()
+// RestoreBuilder rebuilds an InscriptionBuilder from the hex of the commit and reveal txs it
+// produced for request, so that fees and inscription ids can be recomputed without signing again.
+func RestoreBuilder(network *chaincfg.Params, request *InscriptionRequest, commitHex string, revealHexes []string) (*InscriptionBuilder, error) {
+	singleReveal := request.SingleRevealTx || request.AggregateReveal
+	if singleReveal && len(revealHexes) != 1 {
+		return nil, fmt.Errorf("got %d reveal txs for a single reveal tx", len(revealHexes))
+	}
+	if !singleReveal && len(revealHexes) != len(request.InscriptionDataList) {
+		return nil, fmt.Errorf("got %d reveal txs for %d inscriptions", len(revealHexes), len(request.InscriptionDataList))
+	}
+	ctxList, err := buildInscriptionScriptCtxList(request, network)
 	if err != nil {
 		return nil, err
 	}
-
-	tapHash := proof.RootNode.TapHash()
-	commitTxAddress, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(txscript.ComputeTaprootOutputKey(privateKey.PubKey(), tapHash[:])), network)
+	_, revealFeeRates, err := normalizeFeeRates(network, request)
 	if err != nil {
 		return nil, err
 	}
-	commitTxAddressPkScript, err := txscript.PayToAddrScript(commitTxAddress)
+	if singleReveal {
+		// the single reveal tx pays the highest rate of the inscriptions
+		singleRate := int64(0)
+		for _, rate := range revealFeeRates {
+			if rate > singleRate {
+				singleRate = rate
+			}
+		}
+		revealFeeRates = []int64{singleRate}
+	}
+	commitTx, err := NewTxFromHex(commitHex)
 	if err != nil {
 		return nil, err
 	}
-
-	return &inscriptionTxCtxData{
-		PrivateKey:              privateKey,
-		InscriptionScript:       inscriptionScript,
-		CommitTxAddress:         commitTxAddress.EncodeAddress(),
-		CommitTxAddressPkScript: commitTxAddressPkScript,
-		ControlBlockWitness:     controlBlockWitness,
-	}, nil
-}
-
-func (builder *InscriptionBuilder) buildEmptyRevealTx(destination []string, revealOutValue, revealFeeRate int64) (int64, error) {
-	addTxInTxOutIntoRevealTx := func(tx *wire.MsgTx, index int) error {
-		in := wire.NewTxIn(&wire.OutPoint{Index: uint32(index)}, nil, nil)
-		in.Sequence = DefaultSequenceNum
-		tx.AddTxIn(in)
-		scriptPubKey, err := AddrToPkScript(destination[index], builder.Network)
+	builder := &InscriptionBuilder{
+		Network:                   network,
+		CommitTxPrevOutputFetcher: txscript.NewMultiPrevOutFetcher(nil),
+		InscriptionTxCtxDataList:  ctxList,
+		RevealTxPrevOutputFetcher: txscript.NewMultiPrevOutFetcher(nil),
+		CommitTxPrevOutputList:    request.CommitTxPrevOutputList,
+		InscriptionDataList:       request.InscriptionDataList,
+		CommitTx:                  commitTx,
+		CommitAddrs:               make([]string, len(ctxList)),
+		FeeRoundingMode:           request.FeeRoundingMode,
+		FeeBufferPercent:          request.FeeBufferPercent,
+		SigHashCache:              request.SigHashCache,
+		RevealFeeRates:            revealFeeRates,
+		SingleRevealTx:            request.SingleRevealTx,
+		AggregateReveal:           request.AggregateReveal,
+	}
+	for _, prevOutput := range request.CommitTxPrevOutputList {
+		txHash, err := chainhash.NewHashFromStr(prevOutput.TxId)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		out := wire.NewTxOut(revealOutValue, scriptPubKey)
-		tx.AddTxOut(out)
-		return nil
+		pkScript, err := AddrToPkScript(prevOutput.Address, network)
+		if err != nil {
+			return nil, err
+		}
+		builder.CommitTxPrevOutputFetcher.AddPrevOut(*wire.NewOutPoint(txHash, prevOutput.VOut), wire.NewTxOut(prevOutput.Amount, pkScript))
 	}
-
-	totalPrevOutputValue := int64(0)
-	total := len(builder.InscriptionTxCtxDataList)
-	revealTx := make([]*wire.MsgTx, total)
-	mustRevealTxFees := make([]int64, total)
-	commitAddrs := make([]string, total)
-	for i := 0; i < total; i++ {
-		tx := wire.NewMsgTx(DefaultTxVersion)
-		err := addTxInTxOutIntoRevealTx(tx, i)
+	for _, in := range commitTx.TxIn {
+		if builder.CommitTxPrevOutputFetcher.FetchPrevOutput(in.PreviousOutPoint) == nil {
+			return nil, fmt.Errorf("commit tx input %s is not in the request", in.PreviousOutPoint)
+		}
+	}
+	commitTxHash := commitTx.TxHash()
+	for i, ctx := range ctxList {
+		if int(ctx.CommitTxOutIndex) >= len(commitTx.TxOut) || !bytes.Equal(commitTx.TxOut[ctx.CommitTxOutIndex].PkScript, ctx.CommitTxAddressPkScript) {
+			return nil, fmt.Errorf("commit tx output %d does not commit to inscription(index %d)", ctx.CommitTxOutIndex, i)
+		}
+		ctx.RevealTxPrevOutput = commitTx.TxOut[ctx.CommitTxOutIndex]
+		builder.RevealTxPrevOutputFetcher.AddPrevOut(wire.OutPoint{Hash: commitTxHash, Index: ctx.CommitTxOutIndex}, ctx.RevealTxPrevOutput)
+		if ctx.ParentPrevOutput != nil {
+			builder.RevealTxPrevOutputFetcher.AddPrevOut(*ctx.ParentOutPoint, ctx.ParentPrevOutput)
+		}
+		builder.CommitAddrs[i] = ctx.CommitTxAddress
+	}
+	for i, revealHex := range revealHexes {
+		revealTx, err := NewTxFromHex(revealHex)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
-		prevOutputValue := revealOutValue + int64(tx.SerializeSize())*revealFeeRate
-		emptySignature := make([]byte, 64)
-		emptyControlBlockWitness := make([]byte, 33)
-		fee := (int64(wire.TxWitness{emptySignature, builder.InscriptionTxCtxDataList[i].InscriptionScript, emptyControlBlockWitness}.SerializeSize()+2+3) / 4) * revealFeeRate
-		prevOutputValue += fee
-		builder.InscriptionTxCtxDataList[i].RevealTxPrevOutput = &wire.TxOut{
-			PkScript: builder.InscriptionTxCtxDataList[i].CommitTxAddressPkScript,
-			Value:    prevOutputValue,
+		for j, in := range revealTx.TxIn {
+			if builder.RevealTxPrevOutputFetcher.FetchPrevOutput(in.PreviousOutPoint) == nil {
+				return nil, fmt.Errorf("reveal(index %d) input %d does not spend an inscription commit output", i, j)
+			}
 		}
-		totalPrevOutputValue += prevOutputValue
-		revealTx[i] = tx
-		mustRevealTxFees[i] = int64(tx.SerializeSize())*revealFeeRate + fee
-		commitAddrs[i] = builder.InscriptionTxCtxDataList[i].CommitTxAddress
+		builder.RevealTx = append(builder.RevealTx, revealTx)
 	}
-	builder.RevealTx = revealTx
-	builder.MustRevealTxFees = mustRevealTxFees
-	builder.CommitAddrs = commitAddrs
-
-	return totalPrevOutputValue, nil
+	builder.MustCommitTxFee, builder.MustRevealTxFees = builder.CalculateFee()
+	return builder, nil
 }
 
-func (builder *InscriptionBuilder) buildCommitTx(commitTxPrevOutputList []*PrevOutput, changeAddress string, totalRevealPrevOutputValue, commitFeeRate int64, minChangeValue int64) error {
-	totalSenderAmount := btcutil.Amount(0)
-	tx := wire.NewMsgTx(DefaultTxVersion)
-	changePkScript, err := AddrToPkScript(changeAddress, builder.Network)
+// NewUnsignedInscriptionTool builds the commit and reveal txs of request like NewInscriptionTool
+// without signing them, e.g. to Export the builder to the host holding the keys.
+func NewUnsignedInscriptionTool(network *chaincfg.Params, request *InscriptionRequest) (*InscriptionBuilder, error) {
+	request = inscribedInputsFirst(request)
+	tool, err := newInscriptionBuilder(network, request)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, prevOutput := range commitTxPrevOutputList {
-		txHash, err := chainhash.NewHashFromStr(prevOutput.TxId)
-		if err != nil {
-			return err
-		}
-		outPoint := wire.NewOutPoint(txHash, prevOutput.VOut)
-		pkScript, err := AddrToPkScript(prevOutput.Address, builder.Network)
-		if err != nil {
-			return err
+	if err := tool.buildTxs(context.Background(), network, request); err != nil {
+		return tool, err
+	}
+	// drop the signatures made to size the txs
+	for _, tx := range append([]*wire.MsgTx{tool.CommitTx}, tool.RevealTx...) {
+		for _, in := range tx.TxIn {
+			in.SignatureScript = nil
+			in.Witness = nil
 		}
-		txOut := wire.NewTxOut(prevOutput.Amount, pkScript)
-		builder.CommitTxPrevOutputFetcher.AddPrevOut(*outPoint, txOut)
+	}
+	return tool, nil
+}
 
-		in := wire.NewTxIn(outPoint, nil, nil)
-		in.Sequence = DefaultSequenceNum
-		tx.AddTxIn(in)
+type builderSnapshot struct {
+	Network                string                    `json:"network"`
+	CommitTx               string                    `json:"commitTx"`
+	RevealTxs              []string                  `json:"revealTxs"`
+	CommitTxPrevOutputs    []*snapshotPrevOutput     `json:"commitTxPrevOutputs"`
+	RevealTxPrevOutputs    []*snapshotPrevOutput     `json:"revealTxPrevOutputs"`
+	CommitTxPrevOutputList []*PrevOutput             `json:"commitTxPrevOutputList"`
+	InscriptionDataList    []InscriptionData         `json:"inscriptionDataList"`
+	Inscriptions           []*inscriptionCtxSnapshot `json:"inscriptions"`
+	MustCommitTxFee        int64                     `json:"mustCommitTxFee"`
+	MustRevealTxFees       []int64                   `json:"mustRevealTxFees"`
+	CommitAddrs            []string                  `json:"commitAddrs"`
+	FeeRoundingMode        RoundingMode              `json:"feeRoundingMode"`
+	FeeBufferPercent       int                       `json:"feeBufferPercent"`
+	ExactRevealPostage     bool                      `json:"exactRevealPostage"`
+	RevealFeeRates         []int64                   `json:"revealFeeRates"`
+	DisableRBF             bool                      `json:"disableRBF"`
+	SingleRevealTx         bool                      `json:"singleRevealTx"`
+	AggregateReveal        bool                      `json:"aggregateReveal"`
+	AllowDust              bool                      `json:"allowDust"`
+	Deterministic          bool                      `json:"deterministic"`
+	CommitSigHashType      txscript.SigHashType      `json:"commitSigHashType"`
+	Warnings               []string                  `json:"warnings,omitempty"`
+}
 
-		totalSenderAmount += btcutil.Amount(prevOutput.Amount)
-	}
-	for i := range builder.InscriptionTxCtxDataList {
-		tx.AddTxOut(builder.InscriptionTxCtxDataList[i].RevealTxPrevOutput)
-	}
+type snapshotPrevOutput struct {
+	TxId     string `json:"txId"`
+	VOut     uint32 `json:"vOut"`
+	Value    int64  `json:"value"`
+	PkScript []byte `json:"pkScript"`
+}
 
-	tx.AddTxOut(wire.NewTxOut(0, changePkScript))
+type inscriptionCtxSnapshot struct {
+	InscriptionScript       []byte              `json:"inscriptionScript"`
+	CommitTxAddress         string              `json:"commitTxAddress"`
+	CommitTxAddressPkScript []byte              `json:"commitTxAddressPkScript"`
+	ControlBlockWitness     []byte              `json:"controlBlockWitness"`
+	RevealTxPrevOutput      *wire.TxOut         `json:"revealTxPrevOutput"`
+	CommitTxOutIndex        uint32              `json:"commitTxOutIndex"`
+	TapMerkleRoot           []byte              `json:"tapMerkleRoot"`
+	Parent                  *snapshotPrevOutput `json:"parent,omitempty"`
+	ExtraWitness            [][]byte            `json:"extraWitness,omitempty"`
+	Annex                   []byte              `json:"annex,omitempty"`
+}
 
-	txForEstimate := wire.NewMsgTx(DefaultTxVersion)
-	txForEstimate.TxIn = tx.TxIn
-	txForEstimate.TxOut = tx.TxOut
-	if err = Sign(txForEstimate, builder.CommitTxPrivateKeyList, builder.CommitTxPrevOutputFetcher); err != nil {
-		return err
+func newSnapshotPrevOutput(outPoint wire.OutPoint, txOut *wire.TxOut) *snapshotPrevOutput {
+	return &snapshotPrevOutput{TxId: outPoint.Hash.String(), VOut: outPoint.Index, Value: txOut.Value, PkScript: txOut.PkScript}
+}
+
+func (prevOutput *snapshotPrevOutput) outPoint() (*wire.OutPoint, *wire.TxOut, error) {
+	txHash, err := chainhash.NewHashFromStr(prevOutput.TxId)
+	if err != nil {
+		return nil, nil, err
 	}
+	return wire.NewOutPoint(txHash, prevOutput.VOut), wire.NewTxOut(prevOutput.Value, prevOutput.PkScript), nil
+}
 
-	fee := btcutil.Amount(GetTxVirtualSize(btcutil.NewTx(txForEstimate))) * btcutil.Amount(commitFeeRate)
-	changeAmount := totalSenderAmount - btcutil.Amount(totalRevealPrevOutputValue) - fee
-	if int64(changeAmount) >= minChangeValue {
-		tx.TxOut[len(tx.TxOut)-1].Value = int64(changeAmount)
-	} else {
-		tx.TxOut = tx.TxOut[:len(tx.TxOut)-1]
-		if changeAmount < 0 {
-			txForEstimate.TxOut = txForEstimate.TxOut[:len(txForEstimate.TxOut)-1]
-			feeWithoutChange := btcutil.Amount(GetTxVirtualSize(btcutil.NewTx(txForEstimate))) * btcutil.Amount(commitFeeRate)
-			if totalSenderAmount-btcutil.Amount(totalRevealPrevOutputValue)-feeWithoutChange < 0 {
-				builder.MustCommitTxFee = int64(fee)
-				return errors.New("insufficient balance")
+// txPrevOutputs returns the prev outputs known to fetcher of the inputs of txs.
+func txPrevOutputs(fetcher *txscript.MultiPrevOutFetcher, txs ...*wire.MsgTx) []*snapshotPrevOutput {
+	var prevOutputs []*snapshotPrevOutput
+	for _, tx := range txs {
+		for _, in := range tx.TxIn {
+			if txOut := fetcher.FetchPrevOutput(in.PreviousOutPoint); txOut != nil {
+				prevOutputs = append(prevOutputs, newSnapshotPrevOutput(in.PreviousOutPoint, txOut))
 			}
 		}
 	}
-	builder.CommitTx = tx
-	return nil
+	return prevOutputs
 }
 
-func (builder *InscriptionBuilder) completeRevealTx() error {
-	for i := range builder.InscriptionTxCtxDataList {
-		builder.RevealTxPrevOutputFetcher.AddPrevOut(wire.OutPoint{
-			Hash:  builder.CommitTx.TxHash(),
-			Index: uint32(i),
-		}, builder.InscriptionTxCtxDataList[i].RevealTxPrevOutput)
-		builder.RevealTx[i].TxIn[0].PreviousOutPoint.Hash = builder.CommitTx.TxHash()
+// Export serializes the txs, prev outputs and inscription scripts of the builder to json without
+// any private key, so that it can be signed on another host with ImportInscriptionBuilder.
+func (builder *InscriptionBuilder) Export() ([]byte, error) {
+	if builder.CommitTx == nil {
+		return nil, errors.New("commit tx is not built")
+	}
+	snapshot := &builderSnapshot{
+		Network:             builder.Network.Name,
+		RevealTxs:           make([]string, len(builder.RevealTx)),
+		CommitTxPrevOutputs: txPrevOutputs(builder.CommitTxPrevOutputFetcher, builder.CommitTx),
+		RevealTxPrevOutputs: txPrevOutputs(builder.RevealTxPrevOutputFetcher, builder.RevealTx...),
+		InscriptionDataList: make([]InscriptionData, len(builder.InscriptionDataList)),
+		MustCommitTxFee:     builder.MustCommitTxFee,
+		MustRevealTxFees:    builder.MustRevealTxFees,
+		CommitAddrs:         builder.CommitAddrs,
+		FeeRoundingMode:     builder.FeeRoundingMode,
+		FeeBufferPercent:    builder.FeeBufferPercent,
+		ExactRevealPostage:  builder.ExactRevealPostage,
+		RevealFeeRates:      builder.RevealFeeRates,
+		DisableRBF:          builder.DisableRBF,
+		SingleRevealTx:      builder.SingleRevealTx,
+		AggregateReveal:     builder.AggregateReveal,
+		AllowDust:           builder.AllowDust,
+		Deterministic:       builder.Deterministic,
+		CommitSigHashType:   builder.CommitSigHashType,
+		Warnings:            builder.warnings,
+	}
+	var err error
+	if snapshot.CommitTx, err = GetTxHex(builder.CommitTx); err != nil {
+		return nil, err
+	}
+	for i, tx := range builder.RevealTx {
+		if snapshot.RevealTxs[i], err = GetTxHex(tx); err != nil {
+			return nil, err
+		}
+	}
+	for _, prevOutput := range builder.CommitTxPrevOutputList {
+		withoutKey := *prevOutput
+		withoutKey.PrivateKey = ""
+		withoutKey.PrivateKeys = nil
+		snapshot.CommitTxPrevOutputList = append(snapshot.CommitTxPrevOutputList, &withoutKey)
+	}
+	for i, data := range builder.InscriptionDataList {
+		data.RevealPrivateKey = ""
+		if data.ParentPrevOutput != nil {
+			parent := *data.ParentPrevOutput
+			parent.PrivateKey = ""
+			data.ParentPrevOutput = &parent
+		}
+		snapshot.InscriptionDataList[i] = data
+	}
+	for _, ctx := range builder.InscriptionTxCtxDataList {
+		ctxSnapshot := &inscriptionCtxSnapshot{
+			InscriptionScript:       ctx.InscriptionScript,
+			CommitTxAddress:         ctx.CommitTxAddress,
+			CommitTxAddressPkScript: ctx.CommitTxAddressPkScript,
+			ControlBlockWitness:     ctx.ControlBlockWitness,
+			RevealTxPrevOutput:      ctx.RevealTxPrevOutput,
+			CommitTxOutIndex:        ctx.CommitTxOutIndex,
+			TapMerkleRoot:           ctx.TapMerkleRoot,
+			ExtraWitness:            ctx.ExtraWitness,
+			Annex:                   ctx.Annex,
+		}
+		if ctx.ParentOutPoint != nil {
+			ctxSnapshot.Parent = newSnapshotPrevOutput(*ctx.ParentOutPoint, ctx.ParentPrevOutput)
+		}
+		snapshot.Inscriptions = append(snapshot.Inscriptions, ctxSnapshot)
+	}
+	return json.Marshal(snapshot)
+}
+
+// ImportInscriptionBuilder restores a builder from the json of Export. It has no private keys:
+// its reveal sighashes can be signed externally, or SetPrivateKeys supplies the keys before
+// CompleteSigning signs the txs.
+func ImportInscriptionBuilder(data []byte) (*InscriptionBuilder, error) {
+	var snapshot builderSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	network, err := networkByName(snapshot.Network)
+	if err != nil {
+		return nil, err
+	}
+	builder := &InscriptionBuilder{
+		Network:                   network,
+		CommitTxPrevOutputFetcher: txscript.NewMultiPrevOutFetcher(nil),
+		RevealTxPrevOutputFetcher: txscript.NewMultiPrevOutFetcher(nil),
+		CommitTxPrevOutputList:    snapshot.CommitTxPrevOutputList,
+		InscriptionDataList:       snapshot.InscriptionDataList,
+		MustCommitTxFee:           snapshot.MustCommitTxFee,
+		MustRevealTxFees:          snapshot.MustRevealTxFees,
+		CommitAddrs:               snapshot.CommitAddrs,
+		FeeRoundingMode:           snapshot.FeeRoundingMode,
+		FeeBufferPercent:          snapshot.FeeBufferPercent,
+		ExactRevealPostage:        snapshot.ExactRevealPostage,
+		RevealFeeRates:            snapshot.RevealFeeRates,
+		DisableRBF:                snapshot.DisableRBF,
+		SingleRevealTx:            snapshot.SingleRevealTx,
+		AggregateReveal:           snapshot.AggregateReveal,
+		AllowDust:                 snapshot.AllowDust,
+		Deterministic:             snapshot.Deterministic,
+		CommitSigHashType:         snapshot.CommitSigHashType,
+		warnings:                  snapshot.Warnings,
+	}
+	if builder.CommitTx, err = NewTxFromHex(snapshot.CommitTx); err != nil {
+		return nil, fmt.Errorf("commit tx error: %w", err)
+	}
+	for i, revealHex := range snapshot.RevealTxs {
+		revealTx, err := NewTxFromHex(revealHex)
+		if err != nil {
+			return nil, fmt.Errorf("reveal(index %d) tx error: %w", i, err)
+		}
+		builder.RevealTx = append(builder.RevealTx, revealTx)
+	}
+	for _, prevOutputs := range []struct {
+		fetcher *txscript.MultiPrevOutFetcher
+		list    []*snapshotPrevOutput
+	}{
+		{builder.CommitTxPrevOutputFetcher, snapshot.CommitTxPrevOutputs},
+		{builder.RevealTxPrevOutputFetcher, snapshot.RevealTxPrevOutputs},
+	} {
+		for _, prevOutput := range prevOutputs.list {
+			outPoint, txOut, err := prevOutput.outPoint()
+			if err != nil {
+				return nil, err
+			}
+			prevOutputs.fetcher.AddPrevOut(*outPoint, txOut)
+		}
+	}
+	for _, ctxSnapshot := range snapshot.Inscriptions {
+		ctx := &inscriptionTxCtxData{
+			InscriptionScript:       ctxSnapshot.InscriptionScript,
+			CommitTxAddress:         ctxSnapshot.CommitTxAddress,
+			CommitTxAddressPkScript: ctxSnapshot.CommitTxAddressPkScript,
+			ControlBlockWitness:     ctxSnapshot.ControlBlockWitness,
+			RevealTxPrevOutput:      ctxSnapshot.RevealTxPrevOutput,
+			CommitTxOutIndex:        ctxSnapshot.CommitTxOutIndex,
+			TapMerkleRoot:           ctxSnapshot.TapMerkleRoot,
+			ExtraWitness:            ctxSnapshot.ExtraWitness,
+			Annex:                   ctxSnapshot.Annex,
+		}
+		if ctxSnapshot.Parent != nil {
+			if ctx.ParentOutPoint, ctx.ParentPrevOutput, err = ctxSnapshot.Parent.outPoint(); err != nil {
+				return nil, err
+			}
+		}
+		builder.InscriptionTxCtxDataList = append(builder.InscriptionTxCtxDataList, ctx)
+	}
+	return builder, nil
+}
+
+// networkByName returns the parameters of the btcd network called name.
+func networkByName(name string) (*chaincfg.Params, error) {
+	for _, network := range []*chaincfg.Params{&chaincfg.MainNetParams, &chaincfg.TestNet3Params, &chaincfg.RegressionNetParams,
+		&chaincfg.SimNetParams, &chaincfg.SigNetParams} {
+		if network.Name == name {
+			return network, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown network %q", name)
+}
+
+// SetPrivateKeys gives an imported builder the keys signing its txs: one key per commit input, the
+// reveal key of every inscription, tweaked when it has a KeyTweak, and the key of the parent of
+// every inscription which has one. parentKeys may be nil when no inscription has a parent.
+func (builder *InscriptionBuilder) SetPrivateKeys(commitKeys, revealKeys, parentKeys []*btcec.PrivateKey) error {
+	if len(commitKeys) != len(builder.CommitTxPrevOutputList) {
+		return fmt.Errorf("got %d commit keys for %d commit inputs", len(commitKeys), len(builder.CommitTxPrevOutputList))
+	}
+	for i, prevOutput := range builder.CommitTxPrevOutputList {
+		if len(prevOutput.RedeemScript) > 0 {
+			return fmt.Errorf("commit input %d is a multisig input, whose keys can not be set", i)
+		}
+		if err := checkPrevOutputKey(i, prevOutput, commitKeys[i], builder.Network); err != nil {
+			return err
+		}
+	}
+	if len(revealKeys) != len(builder.InscriptionTxCtxDataList) {
+		return fmt.Errorf("got %d reveal keys for %d inscriptions", len(revealKeys), len(builder.InscriptionTxCtxDataList))
+	}
+	for i, ctx := range builder.InscriptionTxCtxDataList {
+		internalKey, err := ctx.internalKey()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(schnorr.SerializePubKey(revealKeys[i].PubKey()), schnorr.SerializePubKey(internalKey)) {
+			return fmt.Errorf("reveal key of inscription(index %d) is not the internal key of its commit output", i)
+		}
+		if ctx.ParentOutPoint == nil {
+			continue
+		}
+		if i >= len(parentKeys) || parentKeys[i] == nil {
+			return fmt.Errorf("inscription(index %d) has a parent but no parent key", i)
+		}
+		owns, err := keyOwnsAddress(parentKeys[i], builder.InscriptionDataList[i].ParentPrevOutput.Address, builder.Network)
+		if err != nil {
+			return err
+		}
+		if !owns {
+			return fmt.Errorf("parent key of inscription(index %d) does not own %s", i, builder.InscriptionDataList[i].ParentPrevOutput.Address)
+		}
+	}
+	builder.CommitTxPrivateKeyList = commitKeys
+	for i, ctx := range builder.InscriptionTxCtxDataList {
+		ctx.PrivateKey = revealKeys[i]
+		if ctx.ParentOutPoint != nil {
+			ctx.ParentPrivateKey = parentKeys[i]
+		}
+	}
+	return nil
+}
+
+// CompleteSigning signs the commit tx and the reveal txs of a builder built unsigned or imported,
+// once it has its private keys.
+func (builder *InscriptionBuilder) CompleteSigning() error {
+	if err := builder.signCommitTx(); err != nil {
+		return fmt.Errorf("sign commit tx error: %w", err)
+	}
+	return builder.completeRevealTx(context.Background())
+}
+
+// checkPrevOutputKey checks that the address of prevOutput is one of the addresses of
+// privateKey, otherwise the commit tx input could not be signed.
+func checkPrevOutputKey(index int, prevOutput *PrevOutput, privateKey *btcec.PrivateKey, network *chaincfg.Params) error {
+	if len(prevOutput.TapMerkleRoot) > 0 {
+		return checkTaprootScriptTreeKey(index, prevOutput, privateKey, network)
+	}
+	ok, err := keyOwnsAddress(privateKey, prevOutput.Address, network)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("private key of commit input %d does not match its address %s", index, prevOutput.Address)
+	}
+	return nil
+}
+
+// checkTaprootScriptTreeKey checks that the address of prevOutput is the taproot output of
+// privateKey committing to the script tree of TapMerkleRoot.
+func checkTaprootScriptTreeKey(index int, prevOutput *PrevOutput, privateKey *btcec.PrivateKey, network *chaincfg.Params) error {
+	if len(prevOutput.TapMerkleRoot) != chainhash.HashSize {
+		return fmt.Errorf("tap merkle root of commit input %d is %d bytes, not %d", index, len(prevOutput.TapMerkleRoot), chainhash.HashSize)
+	}
+	pkScript, err := AddrToPkScript(prevOutput.Address, network)
+	if err != nil {
+		return err
+	}
+	outputKey := txscript.ComputeTaprootOutputKey(privateKey.PubKey(), prevOutput.TapMerkleRoot)
+	keyAddress, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), network)
+	if err != nil {
+		return err
+	}
+	keyPkScript, err := txscript.PayToAddrScript(keyAddress)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(pkScript, keyPkScript) {
+		return fmt.Errorf("private key and tap merkle root of commit input %d do not match its address %s", index, prevOutput.Address)
+	}
+	return nil
+}
+
+// keyOwnsAddress reports whether address is one of the single key addresses of privateKey.
+func keyOwnsAddress(privateKey *btcec.PrivateKey, address string, network *chaincfg.Params) (bool, error) {
+	pkScript, err := AddrToPkScript(address, network)
+	if err != nil {
+		return false, err
+	}
+	p2pkh, p2wpkh, p2shwpkh, p2tr, err := AllAddresses(privateKey.PubKey(), network)
+	if err != nil {
+		return false, err
+	}
+	for _, keyAddress := range []string{p2pkh, p2wpkh, p2shwpkh, p2tr} {
+		keyPkScript, err := AddrToPkScript(keyAddress, network)
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(pkScript, keyPkScript) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (builder *InscriptionBuilder) initTool(ctx context.Context, network *chaincfg.Params, request *InscriptionRequest) error {
+	if err := builder.buildTxs(ctx, network, request); err != nil {
+		return err
+	}
+	err := builder.signCommitTx()
+	if err != nil {
+		return errors.New("sign commit tx error")
+	}
+	err = builder.completeRevealTx(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// buildTxs builds the unsigned commit and reveal txs of request.
+func (builder *InscriptionBuilder) buildTxs(ctx context.Context, network *chaincfg.Params, request *InscriptionRequest) error {
+	if err := checkRequestAddresses(network, request); err != nil {
+		return err
+	}
+	if err := checkPreserveFirstSat(request); err != nil {
+		return err
+	}
+	commitFeeRate, minChangeValue, totalRevealPrevOutputValue, err := builder.buildReveals(ctx, network, request)
+	if err != nil {
+		return err
+	}
+	changePkScript, err := requestChangePkScript(network, request)
+	if err != nil {
+		return err
+	}
+	return builder.buildRequestCommitTx(request, changePkScript, totalRevealPrevOutputValue, commitFeeRate, minChangeValue)
+}
+
+// checkRequestAddresses checks that the commit input, reveal and change addresses of request
+// are addresses of network. A segwit address of another network decodes fine otherwise, and a
+// mainnet and testnet mix-up would go unnoticed.
+func checkRequestAddresses(network *chaincfg.Params, request *InscriptionRequest) error {
+	isForNet := func(addr string) bool {
+		address, err := btcutil.DecodeAddress(addr, network)
+		return err == nil && address.IsForNet(network)
+	}
+	for i, prevOutput := range request.CommitTxPrevOutputList {
+		if !isForNet(prevOutput.Address) {
+			return fmt.Errorf("address %s of commit input %d is not a %s address", prevOutput.Address, i, network.Name)
+		}
+	}
+	for i, data := range request.InscriptionDataList {
+		if len(data.RevealPkScript) > 0 {
+			continue
+		}
+		if !isForNet(data.RevealAddr) {
+			return fmt.Errorf("reveal address %s of inscription(index %d) is not a %s address", data.RevealAddr, i, network.Name)
+		}
+	}
+	for i, data := range request.InscriptionDataList {
+		for k, output := range data.ExtraRevealOutputs {
+			if !isForNet(output.Address) {
+				return fmt.Errorf("address %s of extra reveal output %d of inscription(index %d) is not a %s address", output.Address, k, i, network.Name)
+			}
+		}
+	}
+	for i, output := range request.ChangeOutputs {
+		if !isForNet(output.Address) {
+			return fmt.Errorf("%w: %s of change output %d is not a %s address", ErrInvalidChangeAddress, output.Address, i, network.Name)
+		}
+	}
+	if len(request.ChangePkScript) > 0 {
+		return nil
+	}
+	if !isForNet(request.ChangeAddress) {
+		return fmt.Errorf("%w: %s is not a %s address", ErrInvalidChangeAddress, request.ChangeAddress, network.Name)
+	}
+	return nil
+}
+
+// buildReveals builds the inscription scripts and the unsigned reveal txs, returning the
+// commit fee rate, the minimum change value and the total value the commit tx must send
+// to the reveal txs. It stops with the error of ctx once ctx is done.
+func (builder *InscriptionBuilder) buildReveals(ctx context.Context, network *chaincfg.Params, request *InscriptionRequest) (int64, int64, int64, error) {
+	commitFeeRate, revealFeeRates, err := normalizeFeeRates(network, request)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	destinations := make([]string, len(request.InscriptionDataList))
+	_, minChangeValue := NetworkDefaults(network)
+	if request.MinChangeValue > 0 {
+		minChangeValue = request.MinChangeValue
+	}
+	for i := 0; i < len(request.InscriptionDataList); i++ {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, 0, err
+		}
+		inscriptionTxCtxData, err := newInscriptionTxCtxData(network, request, i)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		builder.InscriptionTxCtxDataList[i] = inscriptionTxCtxData
+		destinations[i] = request.InscriptionDataList[i].RevealAddr
+		if warning := contentTypeWarning(i, request.InscriptionDataList[i]); warning != "" {
+			builder.warnings = append(builder.warnings, warning)
+		}
+		if warning := utf8Warning(i, request.InscriptionDataList[i]); warning != "" {
+			builder.warnings = append(builder.warnings, warning)
+		}
+	}
+	if err := assignCommitFundingVouts(builder.InscriptionTxCtxDataList, request.InscriptionDataList, inscribedInputCount(request.CommitTxPrevOutputList)); err != nil {
+		return 0, 0, 0, err
+	}
+	if request.AggregateReveal {
+		if err := aggregateRevealCtxList(network, request, builder.InscriptionTxCtxDataList); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	totalRevealPrevOutputValue, err := builder.buildEmptyRevealTx(destinations, revealOutValues(request), revealFeeRates)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return commitFeeRate, minChangeValue, totalRevealPrevOutputValue, nil
+}
+
+// contentTypeWarning returns a warning when the declared content type of data disagrees with
+// the type sniffed from its body by http.DetectContentType, or "" when they agree or the body
+// has no recognizable signature. Bodies which are already encoded are not sniffed.
+func contentTypeWarning(index int, data InscriptionData) string {
+	if len(data.Body) == 0 || (data.ContentEncoding != "" && !data.CompressBody) {
+		return ""
+	}
+	declared := mediaType(data.ContentType)
+	sniffed := mediaType(http.DetectContentType(data.Body))
+	switch {
+	case sniffed == declared, sniffed == "application/octet-stream":
+		return ""
+	// textual formats like json or javascript are only sniffed as plain text
+	case sniffed == "text/plain" && (strings.HasPrefix(declared, "text/") || strings.HasPrefix(declared, "application/")):
+		return ""
+	case sniffed == "text/xml" && strings.HasSuffix(declared, "xml"):
+		return ""
+	}
+	return fmt.Sprintf("inscription(index %d) declares content type %s but its body looks like %s", index, data.ContentType, sniffed)
+}
+
+// utf8Warning returns a warning when data is text declared as utf-8 but its body is not valid
+// utf-8, or "" otherwise.
+func utf8Warning(index int, data InscriptionData) string {
+	mt, params, err := mime.ParseMediaType(data.ContentType)
+	if err != nil || !strings.HasPrefix(mt, "text/") || !strings.EqualFold(params["charset"], "utf-8") {
+		return ""
+	}
+	if data.ContentEncoding != "" && !data.CompressBody {
+		return ""
+	}
+	if utf8.Valid(data.Body) {
+		return ""
+	}
+	return fmt.Sprintf("inscription(index %d) declares content type %s but its body is not valid utf-8", index, data.ContentType)
+}
+
+func mediaType(contentType string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+}
+
+// contentCategories maps media types, or their top level type followed by a slash, to the
+// category they are rendered as.
+var contentCategories = map[string]string{
+	"image/":                   "image",
+	"text/":                    "text",
+	"video/":                   "video",
+	"model/":                   "model",
+	"application/json":         "text",
+	"application/javascript":   "text",
+	"application/yaml":         "text",
+	"application/x-javascript": "text",
+}
+
+// ContentCategory classifies contentType as image, text, video or model for rendering,
+// returning other for anything else.
+func ContentCategory(contentType string) string {
+	mt := mediaType(contentType)
+	if category, ok := contentCategories[mt]; ok {
+		return category
+	}
+	if i := strings.Index(mt, "/"); i > 0 {
+		if category, ok := contentCategories[mt[:i+1]]; ok {
+			return category
+		}
+	}
+	return "other"
+}
+
+// revealOutValues returns the reveal output value of every inscription of request, its own
+// RevealOutValue if set, or else the RevealOutValue of request, or else the DefaultPostageByType
+// entry of the content type, falling back to DefaultRevealOutValue.
+func revealOutValues(request *InscriptionRequest) []int64 {
+	values := make([]int64, len(request.InscriptionDataList))
+	for i, data := range request.InscriptionDataList {
+		values[i] = DefaultRevealOutValue
+		if data.RevealOutValue > 0 {
+			values[i] = data.RevealOutValue
+			continue
+		}
+		if request.RevealOutValue > 0 {
+			values[i] = request.RevealOutValue
+			continue
+		}
+		mt := mediaType(data.ContentType)
+		if postage, ok := request.DefaultPostageByType[mt]; ok && postage > 0 {
+			values[i] = postage
+		} else if k := strings.Index(mt, "/"); k > 0 {
+			if postage, ok := request.DefaultPostageByType[mt[:k+1]]; ok && postage > 0 {
+				values[i] = postage
+			}
+		}
+	}
+	return values
+}
+
+// tweakPrivateKey returns privateKey plus the 32 bytes scalar tweak modulo the curve order.
+func tweakPrivateKey(privateKey *btcec.PrivateKey, tweak []byte) (*btcec.PrivateKey, error) {
+	if len(tweak) != 32 {
+		return nil, fmt.Errorf("tweak must be 32 bytes, got %d", len(tweak))
+	}
+	var tweakScalar btcec.ModNScalar
+	if overflow := tweakScalar.SetByteSlice(tweak); overflow {
+		return nil, errors.New("tweak is not below the curve order")
+	}
+	key := privateKey.Key
+	key.Add(&tweakScalar)
+	if key.IsZero() {
+		return nil, errors.New("tweaked key is zero")
+	}
+	return btcec.PrivKeyFromScalar(&key), nil
+}
+
+// revealPrivateKey returns the key of the reveal tapscript of the inscription at index, its
+// RevealPrivateKey if set, else the RevealInternalKey of the request if set, or else the key of
+// the first commit input.
+func revealPrivateKey(network *chaincfg.Params, inscriptionRequest *InscriptionRequest, index int) (*btcec.PrivateKey, error) {
+	if key := inscriptionRequest.InscriptionDataList[index].RevealPrivateKey; key != "" {
+		privateKey, err := decodeRevealKey(network, key)
+		if err != nil {
+			return nil, fmt.Errorf("reveal private key of inscription(index %d) error: %w", index, err)
+		}
+		return privateKey, nil
+	}
+	if inscriptionRequest.RevealInternalKey == "" {
+		privateKeyWif, err := btcutil.DecodeWIF(inscriptionRequest.CommitTxPrevOutputList[0].PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return privateKeyWif.PrivKey, nil
+	}
+	privateKey, err := decodeRevealKey(network, inscriptionRequest.RevealInternalKey)
+	if err != nil {
+		return nil, fmt.Errorf("reveal internal key error: %w", err)
+	}
+	return privateKey, nil
+}
+
+// decodeRevealKey decodes a WIF, which must be for network, or hex private key.
+func decodeRevealKey(network *chaincfg.Params, key string) (*btcec.PrivateKey, error) {
+	if privateKeyWif, err := btcutil.DecodeWIF(key); err == nil {
+		if !privateKeyWif.IsForNet(network) {
+			return nil, fmt.Errorf("key is not for network %s", network.Name)
+		}
+		return privateKeyWif.PrivKey, nil
+	}
+	privateKeyBytes, err := hex.DecodeString(key)
+	if err != nil || len(privateKeyBytes) != btcec.PrivKeyBytesLen {
+		return nil, errors.New("invalid private key")
+	}
+	privateKey, _ := btcec.PrivKeyFromBytes(privateKeyBytes)
+	return privateKey, nil
+}
+
+// envelopePrefix returns the EnvelopePrefix of request, or OrdPrefix when it is empty.
+func envelopePrefix(request *InscriptionRequest) string {
+	if request.EnvelopePrefix == "" {
+		return OrdPrefix
+	}
+	return request.EnvelopePrefix
+}
+
+// buildInscriptionScript returns the tapscript checking the signature of pubKey, an x-only key,
+// followed by the envelope of data marked with prefix.
+func buildInscriptionScript(pubKey []byte, prefix string, data InscriptionData) ([]byte, error) {
+	inscriptionScript, err := txscript.NewScriptBuilder().
+		AddData(pubKey).
+		AddOp(txscript.OP_CHECKSIG).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+	envelope, err := buildEnvelope(prefix, data)
+	if err != nil {
+		return nil, err
+	}
+	return append(inscriptionScript, envelope...), nil
+}
+
+// buildEnvelope returns the envelope of data marked with prefix, from OP_FALSE OP_IF to OP_ENDIF.
+func buildEnvelope(prefix string, data InscriptionData) ([]byte, error) {
+	inscriptionBuilder := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_FALSE).
+		AddOp(txscript.OP_IF).
+		AddData([]byte(prefix))
+	if data.DelegateInscriptionId != "" {
+		if len(data.Body) > 0 || data.ContentEncoding != "" {
+			return nil, errors.New("an inscription with a delegate has no body nor content encoding")
+		}
+		delegate, err := inscriptionIdBytes(data.DelegateInscriptionId)
+		if err != nil {
+			return nil, err
+		}
+		inscriptionBuilder.AddOp(txscript.OP_DATA_1).
+			AddOp(byte(TagDelegate)).
+			AddData(delegate)
+	} else {
+		inscriptionBuilder.AddOp(txscript.OP_DATA_1).
+			AddOp(byte(TagContentType)).
+			AddData([]byte(data.ContentType))
+	}
+	if data.ContentEncoding != "" {
+		inscriptionBuilder.AddOp(txscript.OP_DATA_1).
+			AddOp(byte(TagContentEncoding)).
+			AddData([]byte(data.ContentEncoding))
+	}
+	if data.Pointer != nil {
+		// push the bytes explicitly, a canonical push would turn small values into OP_1-OP_16
+		pointer := pointerBytes(*data.Pointer)
+		inscriptionBuilder.AddOp(txscript.OP_DATA_1).
+			AddOp(byte(TagPointer)).
+			AddOp(byte(len(pointer)))
+		for _, b := range pointer {
+			inscriptionBuilder.AddOp(b)
+		}
+	}
+	if data.ParentInscriptionId != "" {
+		parent, err := inscriptionIdBytes(data.ParentInscriptionId)
+		if err != nil {
+			return nil, err
+		}
+		inscriptionBuilder.AddOp(txscript.OP_DATA_1).
+			AddOp(byte(TagParent)).
+			AddData(parent)
+	}
+	maxChunkSize := 520
+	for i := 0; i < len(data.Metadata); i += maxChunkSize {
+		end := i + maxChunkSize
+		if end > len(data.Metadata) {
+			end = len(data.Metadata)
+		}
+		inscriptionBuilder.AddOp(txscript.OP_DATA_1).
+			AddOp(byte(TagMetadata)).
+			AddFullData(data.Metadata[i:end])
+	}
+	if data.DelegateInscriptionId != "" {
+		inscriptionScript, err := inscriptionBuilder.Script()
+		if err != nil {
+			return nil, err
+		}
+		return append(inscriptionScript, txscript.OP_ENDIF), nil
+	}
+	inscriptionBuilder.AddOp(txscript.OP_0)
+	// use taproot to skip txscript.MaxScriptSize 10000
+	bodySize := len(data.Body)
+	for i := 0; i < bodySize; i += maxChunkSize {
+		end := i + maxChunkSize
+		if end > bodySize {
+			end = bodySize
+		}
+
+		inscriptionBuilder.AddFullData(data.Body[i:end])
+	}
+	inscriptionScript, err := inscriptionBuilder.Script()
+	if err != nil {
+		return nil, err
+	}
+	return append(inscriptionScript, txscript.OP_ENDIF), nil
+}
+
+// inscriptionIdBytes serializes an inscription id as the 32 bytes of its txid in little-endian
+// order followed by its index as 4 little-endian bytes.
+func inscriptionIdBytes(id string) ([]byte, error) {
+	sep := strings.LastIndex(id, "i")
+	if sep < 0 {
+		return nil, fmt.Errorf("invalid inscription id %s", id)
+	}
+	txHash, err := chainhash.NewHashFromStr(id[:sep])
+	if err != nil || len(id[:sep]) != chainhash.MaxHashStringSize {
+		return nil, fmt.Errorf("invalid inscription id %s", id)
+	}
+	index, err := strconv.ParseUint(id[sep+1:], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid inscription id %s", id)
+	}
+	b := make([]byte, chainhash.HashSize+4)
+	copy(b, txHash[:])
+	binary.LittleEndian.PutUint32(b[chainhash.HashSize:], uint32(index))
+	return b, nil
+}
+
+// inscriptionIdFromBytes is the inverse of inscriptionIdBytes, also accepting an index with its
+// trailing zero bytes omitted.
+func inscriptionIdFromBytes(b []byte) (string, error) {
+	if len(b) < chainhash.HashSize || len(b) > chainhash.HashSize+4 {
+		return "", fmt.Errorf("invalid inscription id length %d", len(b))
+	}
+	txHash, err := chainhash.NewHash(b[:chainhash.HashSize])
+	if err != nil {
+		return "", err
+	}
+	index := make([]byte, 4)
+	copy(index, b[chainhash.HashSize:])
+	return fmt.Sprintf("%si%d", txHash, binary.LittleEndian.Uint32(index)), nil
+}
+
+// ParseInscription decodes the inscription envelopes of the tapscripts spent by the inputs of
+// the reveal tx, in input order. Envelopes without the ord prefix are skipped and the address of
+// the output at the index of an input, if any, is the RevealAddr of its inscriptions.
+func ParseInscription(revealTxHex string, network *chaincfg.Params) ([]*InscriptionData, error) {
+	return ParseInscriptionWithPrefix(revealTxHex, OrdPrefix, network)
+}
+
+// ParseInscriptionWithPrefix is ParseInscription for envelopes marked with prefix instead of
+// the ord prefix, as built with the EnvelopePrefix of the request.
+func ParseInscriptionWithPrefix(revealTxHex string, prefix string, network *chaincfg.Params) ([]*InscriptionData, error) {
+	tx, err := NewTxFromHex(revealTxHex)
+	if err != nil {
+		return nil, err
+	}
+	var dataList []*InscriptionData
+	for i, in := range tx.TxIn {
+		script := witnessTapscript(in.Witness)
+		if script == nil {
+			continue
+		}
+		envelopes, err := parseEnvelopes(script, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+		revealAddr := ""
+		if i < len(tx.TxOut) {
+			_, addrs, _, err := txscript.ExtractPkScriptAddrs(tx.TxOut[i].PkScript, network)
+			if err == nil && len(addrs) == 1 {
+				revealAddr = addrs[0].EncodeAddress()
+			}
+		}
+		for _, data := range envelopes {
+			data.RevealAddr = revealAddr
+			dataList = append(dataList, data)
+		}
+	}
+	if len(dataList) == 0 {
+		return nil, errors.New("reveal tx has no inscription envelope")
+	}
+	return dataList, nil
+}
+
+// PrevOutputsFromTx returns the outputs of the funding tx paying to one of myAddrs, which maps
+// every address to its WIF private key, as PrevOutputs ready for an inscription request.
+func PrevOutputsFromTx(txHex string, myAddrs map[string]string, network *chaincfg.Params) ([]*PrevOutput, error) {
+	tx, err := NewTxFromHex(txHex)
+	if err != nil {
+		return nil, err
+	}
+	addrByPkScript := make(map[string]string, len(myAddrs))
+	for addr := range myAddrs {
+		pkScript, err := AddrToPkScript(addr, network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %s: %w", addr, err)
+		}
+		addrByPkScript[string(pkScript)] = addr
+	}
+	txId := tx.TxHash().String()
+	var prevOutputs []*PrevOutput
+	for i, out := range tx.TxOut {
+		addr, ok := addrByPkScript[string(out.PkScript)]
+		if !ok {
+			continue
+		}
+		prevOutputs = append(prevOutputs, &PrevOutput{
+			TxId:       txId,
+			VOut:       uint32(i),
+			Amount:     out.Value,
+			Address:    addr,
+			PrivateKey: myAddrs[addr],
+		})
+	}
+	if len(prevOutputs) == 0 {
+		return nil, errors.New("funding tx pays to none of the addresses")
+	}
+	return prevOutputs, nil
+}
+
+// witnessTapscript returns the script of a taproot script path spend witness, nil when the
+// witness is too short to be one.
+func witnessTapscript(witness wire.TxWitness) []byte {
+	if len(witness) >= 2 && len(witness[len(witness)-1]) > 0 && witness[len(witness)-1][0] == txscript.TaprootAnnexTag {
+		witness = witness[:len(witness)-1]
+	}
+	if len(witness) < 2 {
+		return nil
+	}
+	return witness[len(witness)-2]
+}
+
+type scriptPush struct {
+	opcode byte
+	data   []byte
+}
+
+// parseEnvelopes decodes every OP_FALSE OP_IF prefix ... OP_ENDIF envelope of script. The fields
+// before the OP_0 separator are read as tag and value pairs, the pushes after it make the body.
+func parseEnvelopes(script []byte, prefix string) ([]*InscriptionData, error) {
+	var pushes []scriptPush
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	for tokenizer.Next() {
+		pushes = append(pushes, scriptPush{opcode: tokenizer.Opcode(), data: tokenizer.Data()})
+	}
+	if err := tokenizer.Err(); err != nil {
+		return nil, err
+	}
+	var dataList []*InscriptionData
+	for i := 0; i+2 < len(pushes); i++ {
+		if pushes[i].opcode != txscript.OP_FALSE || pushes[i+1].opcode != txscript.OP_IF || string(pushes[i+2].data) != prefix {
+			continue
+		}
+		data := &InscriptionData{}
+		k := i + 3
+		for ; k < len(pushes) && pushes[k].opcode != txscript.OP_ENDIF && pushes[k].opcode != txscript.OP_0; k += 2 {
+			if k+1 >= len(pushes) || pushes[k+1].opcode == txscript.OP_ENDIF || len(pushes[k].data) != 1 {
+				return nil, fmt.Errorf("malformed envelope field at opcode %d", k)
+			}
+			value := pushes[k+1].data
+			switch OrdTag(pushes[k].data[0]) {
+			case TagContentType:
+				data.ContentType = string(value)
+			case TagContentEncoding:
+				data.ContentEncoding = string(value)
+			case TagPointer:
+				if len(value) <= 8 {
+					buf := make([]byte, 8)
+					copy(buf, value)
+					pointer := binary.LittleEndian.Uint64(buf)
+					data.Pointer = &pointer
+				}
+			case TagParent:
+				parent, err := inscriptionIdFromBytes(value)
+				if err != nil {
+					return nil, err
+				}
+				data.ParentInscriptionId = parent
+			case TagDelegate:
+				delegate, err := inscriptionIdFromBytes(value)
+				if err != nil {
+					return nil, err
+				}
+				data.DelegateInscriptionId = delegate
+			case TagMetadata:
+				data.Metadata = append(data.Metadata, value...)
+			}
+		}
+		if k < len(pushes) && pushes[k].opcode == txscript.OP_0 {
+			for k++; k < len(pushes) && pushes[k].opcode != txscript.OP_ENDIF; k++ {
+				data.Body = append(data.Body, pushes[k].data...)
+			}
+		}
+		if k >= len(pushes) {
+			return nil, errors.New("envelope is not terminated by OP_ENDIF")
+		}
+		dataList = append(dataList, data)
+		i = k
+	}
+	return dataList, nil
+}
+
+// pointerBytes returns the little endian encoding of pointer without its trailing zero bytes,
+// as ord expects it, which is empty for 0.
+func pointerBytes(pointer uint64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, pointer)
+	return bytes.TrimRight(buf, "\x00")
+}
+
+// EnvelopeSize returns the size in bytes of the reveal tapscript carrying data, which makes up
+// most of the reveal tx weight. It does not depend on the reveal key.
+func EnvelopeSize(data InscriptionData) int {
+	data, err := encodeBody(data)
+	if err != nil {
+		return 0
+	}
+	inscriptionScript, err := buildInscriptionScript(make([]byte, schnorr.PubKeyBytesLen), OrdPrefix, data)
+	if err != nil {
+		return 0
+	}
+	return len(inscriptionScript)
+}
+
+// revealFeeForBodySize returns the fee at revealFeeRate of a reveal tx paying a taproot address
+// and inscribing a body of bodySize bytes with an empty content type.
+func revealFeeForBodySize(bodySize int, revealFeeRate int64) int64 {
+	tx := wire.NewMsgTx(DefaultTxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(0, make([]byte, 34)))
+	inscriptionScript, _ := buildInscriptionScript(make([]byte, schnorr.PubKeyBytesLen), OrdPrefix, InscriptionData{Body: make([]byte, bodySize)})
+	witness := wire.TxWitness{make([]byte, 64), inscriptionScript, make([]byte, 33)}
+	return computeFee(revealTxWeight(tx, witness), revealFeeRate, RoundUp)
+}
+
+// MaxBodySizeForBudget returns the size of the largest body whose reveal, postage included,
+// costs at most budgetSats at revealFeeRate, or 0 when even an empty body does not fit. It
+// assumes a taproot reveal address and an empty content type, every byte of content type
+// takes a byte from the body.
+func MaxBodySizeForBudget(budgetSats, revealFeeRate, postage int64) int {
+	fits := func(bodySize int) bool {
+		return postage+revealFeeForBodySize(bodySize, revealFeeRate) <= budgetSats
+	}
+	if !fits(0) {
+		return 0
+	}
+	// a body byte weighs one unit, so it can not be larger than the weight of a standard tx
+	low, high := 0, MaxStandardTxWeight
+	for low < high {
+		mid := low + (high-low+1)/2
+		if fits(mid) {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return low
+}
+
+// CalculateCommitAddresses returns the taproot commit address of every inscription of dataList
+// revealed with revealKey, the WIF or hex private key of the reveal tapscripts, without building
+// any tx. They are the CommitAddrs of an Inscribe of dataList with revealKey as RevealInternalKey,
+// so they can be shown as deposit addresses before the commit inputs are known.
+func CalculateCommitAddresses(network *chaincfg.Params, dataList []InscriptionData, revealKey string) ([]string, error) {
+	request := &InscriptionRequest{InscriptionDataList: dataList, RevealInternalKey: revealKey}
+	commitAddrs := make([]string, len(dataList))
+	for i, data := range dataList {
+		if revealKey == "" && data.RevealPrivateKey == "" {
+			return nil, fmt.Errorf("inscription(index %d) has no reveal key", i)
+		}
+		ctx, err := newInscriptionTxCtxData(network, request, i)
+		if err != nil {
+			return nil, err
+		}
+		commitAddrs[i] = ctx.CommitTxAddress
+	}
+	return commitAddrs, nil
+}
+
+// encodeBody returns data with its body compressed with its content encoding when CompressBody
+// is set, and data unchanged otherwise.
+func encodeBody(data InscriptionData) (InscriptionData, error) {
+	if !data.CompressBody {
+		return data, nil
+	}
+	if data.ContentEncoding != "gzip" {
+		return data, fmt.Errorf("body compression with content encoding %q is not supported", data.ContentEncoding)
+	}
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return data, err
+	}
+	if _, err := writer.Write(data.Body); err != nil {
+		return data, err
+	}
+	if err := writer.Close(); err != nil {
+		return data, err
+	}
+	data.Body = buf.Bytes()
+	return data, nil
+}
+
+func newInscriptionTxCtxData(network *chaincfg.Params, inscriptionRequest *InscriptionRequest, indexOfInscriptionDataList int) (*inscriptionTxCtxData, error) {
+	privateKey, err := revealPrivateKey(network, inscriptionRequest, indexOfInscriptionDataList)
+	if err != nil {
+		return nil, err
+	}
+	if tweak := inscriptionRequest.InscriptionDataList[indexOfInscriptionDataList].KeyTweak; len(tweak) > 0 {
+		privateKey, err = tweakPrivateKey(privateKey, tweak)
+		if err != nil {
+			return nil, fmt.Errorf("inscription(index %d) key tweak error: %w", indexOfInscriptionDataList, err)
+		}
+	}
+
+	data, err := encodeBody(inscriptionRequest.InscriptionDataList[indexOfInscriptionDataList])
+	if err != nil {
+		return nil, fmt.Errorf("inscription(index %d) %w", indexOfInscriptionDataList, err)
+	}
+	inscriptionScript, err := buildInscriptionScript(schnorr.SerializePubKey(privateKey.PubKey()), envelopePrefix(inscriptionRequest), data)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := newTaprootCommitCtx(network, privateKey, inscriptionScript)
+	if err != nil {
+		return nil, err
+	}
+	ctx.ExtraWitness = inscriptionRequest.InscriptionDataList[indexOfInscriptionDataList].ExtraWitness
+	if annex := inscriptionRequest.InscriptionDataList[indexOfInscriptionDataList].RevealAnnex; len(annex) > 0 {
+		if annex[0] != txscript.TaprootAnnexTag {
+			return nil, fmt.Errorf("reveal annex of inscription(index %d) does not start with 0x%02x", indexOfInscriptionDataList, txscript.TaprootAnnexTag)
+		}
+		ctx.Annex = annex
+	}
+	if err := ctx.setParent(network, indexOfInscriptionDataList, inscriptionRequest.InscriptionDataList[indexOfInscriptionDataList]); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// newTaprootCommitCtx returns the ctx of the commit output whose script tree holds
+// inscriptionScript under the internal key of privateKey.
+func newTaprootCommitCtx(network *chaincfg.Params, privateKey *btcec.PrivateKey, inscriptionScript []byte) (*inscriptionTxCtxData, error) {
+	proof := &txscript.TapscriptProof{
+		TapLeaf:  txscript.NewBaseTapLeaf(schnorr.SerializePubKey(privateKey.PubKey())),
+		RootNode: txscript.NewBaseTapLeaf(inscriptionScript),
+	}
+
+	controlBlock := proof.ToControlBlock(privateKey.PubKey())
+	controlBlockWitness, err := controlBlock.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	tapHash := proof.RootNode.TapHash()
+	commitTxAddress, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(txscript.ComputeTaprootOutputKey(privateKey.PubKey(), tapHash[:])), network)
+	if err != nil {
+		return nil, err
+	}
+	commitTxAddressPkScript, err := txscript.PayToAddrScript(commitTxAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inscriptionTxCtxData{
+		PrivateKey:              privateKey,
+		InscriptionScript:       inscriptionScript,
+		CommitTxAddress:         commitTxAddress.EncodeAddress(),
+		CommitTxAddressPkScript: commitTxAddressPkScript,
+		ControlBlockWitness:     controlBlockWitness,
+		TapMerkleRoot:           tapHash[:],
+	}, nil
+}
+
+// aggregateRevealCtxList makes all the inscriptions of ctxList revealed by one tapscript
+// holding every envelope, committed to by a single commit output. Each inscription after the
+// first points to the first sat of its own reveal output. The reveal key, the extra witness and
+// the annex of the first inscription are used for the shared spend.
+func aggregateRevealCtxList(network *chaincfg.Params, request *InscriptionRequest, ctxList []*inscriptionTxCtxData) error {
+	first := ctxList[0]
+	inscriptionScript, err := txscript.NewScriptBuilder().
+		AddData(schnorr.SerializePubKey(first.PrivateKey.PubKey())).
+		AddOp(txscript.OP_CHECKSIG).
+		Script()
+	if err != nil {
+		return err
+	}
+	values := revealOutValues(request)
+	offset := uint64(0)
+	for i, data := range request.InscriptionDataList {
+		if data.ParentPrevOutput != nil {
+			return fmt.Errorf("inscription(index %d) with a parent can not be aggregated", i)
+		}
+		if data.Pointer != nil {
+			return fmt.Errorf("inscription(index %d) pointer is set by the aggregated reveal", i)
+		}
+		data, err := encodeBody(data)
+		if err != nil {
+			return fmt.Errorf("inscription(index %d) %w", i, err)
+		}
+		if i > 0 {
+			pointer := offset
+			data.Pointer = &pointer
+		}
+		envelope, err := buildEnvelope(envelopePrefix(request), data)
+		if err != nil {
+			return err
+		}
+		inscriptionScript = append(inscriptionScript, envelope...)
+		offset += uint64(values[i] + extraRevealOutputsValue(data))
+	}
+	aggregate, err := newTaprootCommitCtx(network, first.PrivateKey, inscriptionScript)
+	if err != nil {
+		return err
+	}
+	aggregate.ExtraWitness = first.ExtraWitness
+	aggregate.Annex = first.Annex
+	// the shared commit output takes the first of the vouts assigned to the inscriptions
+	aggregate.CommitTxOutIndex = first.CommitTxOutIndex
+	for _, ctx := range ctxList {
+		if ctx.CommitTxOutIndex < aggregate.CommitTxOutIndex {
+			aggregate.CommitTxOutIndex = ctx.CommitTxOutIndex
+		}
+	}
+	for _, ctx := range ctxList {
+		*ctx = *aggregate
+	}
+	return nil
+}
+
+// revealWitness returns the witness spending the commit output of the inscription with
+// signature and controlBlock, followed by its extra witness elements and its annex.
+func (ctx *inscriptionTxCtxData) revealWitness(signature, controlBlock []byte) wire.TxWitness {
+	witness := wire.TxWitness{signature, ctx.InscriptionScript, controlBlock}
+	witness = append(witness, ctx.ExtraWitness...)
+	if len(ctx.Annex) > 0 {
+		witness = append(witness, ctx.Annex)
+	}
+	return witness
+}
+
+// internalKey returns the taproot internal key of the commit output, read from its control block
+// so that it is known without the private key, e.g. in an imported builder.
+func (ctx *inscriptionTxCtxData) internalKey() (*btcec.PublicKey, error) {
+	controlBlock, err := txscript.ParseControlBlock(ctx.ControlBlockWitness)
+	if err != nil {
+		return nil, err
+	}
+	return controlBlock.InternalKey, nil
+}
+
+// setParent decodes the output holding the parent inscription of data, if any, and the key
+// signing its spend in the reveal tx.
+func (ctx *inscriptionTxCtxData) setParent(network *chaincfg.Params, index int, data InscriptionData) error {
+	if (data.ParentInscriptionId == "") != (data.ParentPrevOutput == nil) {
+		return fmt.Errorf("inscription(index %d) needs both a parent inscription id and a parent prev output", index)
+	}
+	if data.ParentPrevOutput == nil {
+		return nil
+	}
+	privateKeyWif, err := btcutil.DecodeWIF(data.ParentPrevOutput.PrivateKey)
+	if err != nil {
+		return err
+	}
+	if !privateKeyWif.IsForNet(network) {
+		return fmt.Errorf("private key of the parent of inscription(index %d) is not for network %s", index, network.Name)
+	}
+	ok, err := keyOwnsAddress(privateKeyWif.PrivKey, data.ParentPrevOutput.Address, network)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("private key of the parent of inscription(index %d) does not match its address %s", index, data.ParentPrevOutput.Address)
+	}
+	txHash, err := chainhash.NewHashFromStr(data.ParentPrevOutput.TxId)
+	if err != nil {
+		return err
+	}
+	pkScript, err := AddrToPkScript(data.ParentPrevOutput.Address, network)
+	if err != nil {
+		return err
+	}
+	ctx.ParentPrivateKey = privateKeyWif.PrivKey
+	ctx.ParentOutPoint = wire.NewOutPoint(txHash, data.ParentPrevOutput.VOut)
+	ctx.ParentPrevOutput = wire.NewTxOut(data.ParentPrevOutput.Amount, pkScript)
+	return nil
+}
+
+func (builder *InscriptionBuilder) buildConsolidationSuggestion(prevOutputList []*PrevOutput, address string, feeRate int64, maxInputs int) (*ConsolidationSuggestion, error) {
+	tx := wire.NewMsgTx(DefaultTxVersion)
+	prevOutFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	totalAmount := int64(0)
+	for _, prevOutput := range prevOutputList {
+		txHash, err := chainhash.NewHashFromStr(prevOutput.TxId)
+		if err != nil {
+			return nil, err
+		}
+		outPoint := wire.NewOutPoint(txHash, prevOutput.VOut)
+		pkScript, err := AddrToPkScript(prevOutput.Address, builder.Network)
+		if err != nil {
+			return nil, err
+		}
+		prevOutFetcher.AddPrevOut(*outPoint, wire.NewTxOut(prevOutput.Amount, pkScript))
+		in := wire.NewTxIn(outPoint, nil, nil)
+		in.Sequence = DefaultSequenceNum
+		tx.AddTxIn(in)
+		totalAmount += prevOutput.Amount
+	}
+	pkScript, err := AddrToPkScript(address, builder.Network)
+	if err != nil {
+		return nil, err
+	}
+	tx.AddTxOut(wire.NewTxOut(0, pkScript))
+	if err := builder.signCommitInputs(tx, prevOutFetcher); err != nil {
+		return nil, err
+	}
+	fee := applyFeeBuffer(computeFee(GetTransactionWeight(btcutil.NewTx(tx)), feeRate, builder.FeeRoundingMode), builder.FeeBufferPercent)
+	return &ConsolidationSuggestion{
+		Inputs:          prevOutputList,
+		Address:         address,
+		Amount:          totalAmount - fee,
+		Fee:             fee,
+		MaxCommitInputs: maxInputs,
+	}, nil
+}
+
+func (builder *InscriptionBuilder) buildEmptyRevealTx(destination []string, revealOutValues []int64, revealFeeRates []int64) (int64, error) {
+	// the parent is spent before the commit output so that its sats map one to one onto the
+	// first output, the reveal fee comes from the tail of the commit input and must not shift
+	// the parent inscription. A commit output shared by an aggregated reveal is spent once, in
+	// which case the returned input is nil.
+	addTxInTxOutIntoRevealTx := func(tx *wire.MsgTx, fetcher *txscript.MultiPrevOutFetcher, index int) (*wire.TxIn, *wire.TxOut, error) {
+		ctx := builder.InscriptionTxCtxDataList[index]
+		scriptPubKey, err := revealPkScript(builder.Network, index, builder.InscriptionDataList[index])
+		if err != nil {
+			return nil, nil, err
+		}
+		if dust := GetDustThreshold(scriptPubKey); revealOutValues[index] < dust && !builder.AllowDust {
+			return nil, nil, fmt.Errorf("reveal(index %d) output value %d is below the dust threshold %d of %s", index, revealOutValues[index], dust, destination[index])
+		}
+		if ctx.ParentPrevOutput != nil {
+			parentIn := wire.NewTxIn(ctx.ParentOutPoint, nil, nil)
+			parentIn.Sequence = inputSequence(builder.DisableRBF)
+			tx.AddTxIn(parentIn)
+			tx.AddTxOut(wire.NewTxOut(ctx.ParentPrevOutput.Value, scriptPubKey))
+			fetcher.AddPrevOut(*ctx.ParentOutPoint, ctx.ParentPrevOutput)
+		}
+		out := wire.NewTxOut(revealOutValues[index], scriptPubKey)
+		extraOuts, err := builder.extraRevealTxOuts(index)
+		if err != nil {
+			return nil, nil, err
+		}
+		commitOutPoint := wire.OutPoint{Index: ctx.CommitTxOutIndex}
+		if fetcher.FetchPrevOutput(commitOutPoint) != nil {
+			tx.AddTxOut(out)
+			for _, extraOut := range extraOuts {
+				tx.AddTxOut(extraOut)
+			}
+			return nil, out, nil
+		}
+		in := wire.NewTxIn(&commitOutPoint, nil, nil)
+		in.Sequence = inputSequence(builder.DisableRBF)
+		tx.AddTxIn(in)
+		tx.AddTxOut(out)
+		for _, extraOut := range extraOuts {
+			tx.AddTxOut(extraOut)
+		}
+		fetcher.AddPrevOut(in.PreviousOutPoint, wire.NewTxOut(0, ctx.CommitTxAddressPkScript))
+		return in, out, nil
+	}
+	// sign the parent inputs for the fee estimate, they are signed again once the reveal tx is
+	// linked to the commit tx
+	signParentsForEstimate := func(tx *wire.MsgTx, fetcher *txscript.MultiPrevOutFetcher) error {
+		txSigHashes := txscript.NewTxSigHashes(tx, fetcher)
+		for j, in := range tx.TxIn {
+			for _, ctx := range builder.InscriptionTxCtxDataList {
+				if ctx.ParentOutPoint == nil || in.PreviousOutPoint != *ctx.ParentOutPoint {
+					continue
+				}
+				if err := SignTxInput1(ctx.ParentPrivateKey, tx, j, txSigHashes, ctx.ParentPrevOutput.PkScript, ctx.ParentPrevOutput.Value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	total := len(builder.InscriptionTxCtxDataList)
+	// every reveal tx reveals a group of inscriptions, one per tx unless SingleRevealTx or
+	// AggregateReveal is set
+	groups := make([][]int, 0, total)
+	for i := 0; i < total; i++ {
+		if (builder.SingleRevealTx || builder.AggregateReveal) && i > 0 {
+			groups[0] = append(groups[0], i)
+			continue
+		}
+		groups = append(groups, []int{i})
+	}
+
+	totalPrevOutputValue := int64(0)
+	revealTx := make([]*wire.MsgTx, len(groups))
+	mustRevealTxFees := make([]int64, len(groups))
+	groupFeeRates := make([]int64, len(groups))
+	commitAddrs := make([]string, total)
+	for g, group := range groups {
+		tx := wire.NewMsgTx(DefaultTxVersion)
+		fetcher := txscript.NewMultiPrevOutFetcher(nil)
+		ins := make([]*wire.TxIn, 0, len(group))
+		outs := make([]*wire.TxOut, len(group))
+		feeBufferPercent := builder.FeeBufferPercent
+		if builder.ExactRevealPostage {
+			feeBufferPercent = 0
+		}
+		for k, i := range group {
+			in, out, err := addTxInTxOutIntoRevealTx(tx, fetcher, i)
+			if err != nil {
+				return 0, err
+			}
+			outs[k] = out
+			if in != nil {
+				emptySignature := make([]byte, 64)
+				emptyControlBlockWitness := make([]byte, 33)
+				in.Witness = builder.InscriptionTxCtxDataList[i].revealWitness(emptySignature, emptyControlBlockWitness)
+				ins = append(ins, in)
+			}
+			if revealFeeRates[i] > groupFeeRates[g] {
+				groupFeeRates[g] = revealFeeRates[i]
+			}
+		}
+		if err := signParentsForEstimate(tx, fetcher); err != nil {
+			return 0, err
+		}
+		weight := GetTransactionWeight(btcutil.NewTx(tx))
+		for _, in := range ins {
+			in.Witness = nil
+		}
+		if weight > MaxStandardTxWeight {
+			return 0, fmt.Errorf("%w: reveal(index %d) weight %d is greater than %d", ErrRevealWeightExceeded, g, weight, MaxStandardTxWeight)
+		}
+		fee := applyFeeBuffer(computeFee(weight, groupFeeRates[g], builder.FeeRoundingMode), feeBufferPercent)
+		// the whole fee comes from the last commit input, the others carry exactly their postage
+		// so that every inscription lands on the first sat of its own reveal output. A shared
+		// commit output carries the postage of all of its inscriptions. The extra reveal outputs
+		// of an inscription are funded by its commit input too, right after its postage.
+		prevOutputs := make(map[uint32]*wire.TxOut, len(ins))
+		for k, i := range group {
+			ctx := builder.InscriptionTxCtxDataList[i]
+			inputFee := int64(0)
+			if k == len(group)-1 {
+				inputFee = fee
+			}
+			if err := checkRevealPrevOutputValue(i, revealOutValues[i]+inputFee, inputFee, outs[k].PkScript, builder.AllowDust); err != nil {
+				return 0, err
+			}
+			prevOutput, ok := prevOutputs[ctx.CommitTxOutIndex]
+			if !ok {
+				prevOutput = &wire.TxOut{PkScript: ctx.CommitTxAddressPkScript}
+				prevOutputs[ctx.CommitTxOutIndex] = prevOutput
+			}
+			extraValue := extraRevealOutputsValue(builder.InscriptionDataList[i])
+			prevOutput.Value += revealOutValues[i] + extraValue + inputFee
+			totalPrevOutputValue += revealOutValues[i] + extraValue + inputFee
+			ctx.RevealTxPrevOutput = prevOutput
+			commitAddrs[i] = ctx.CommitTxAddress
+		}
+		revealTx[g] = tx
+		mustRevealTxFees[g] = fee
+	}
+	builder.RevealTx = revealTx
+	builder.MustRevealTxFees = mustRevealTxFees
+	builder.RevealFeeRates = groupFeeRates
+	builder.CommitAddrs = commitAddrs
+
+	return totalPrevOutputValue, nil
+}
+
+// extraRevealTxOuts returns the extra reveal outputs of the inscription at index, each of them
+// paying a positive value above the dust threshold of its address unless AllowDust is set.
+func (builder *InscriptionBuilder) extraRevealTxOuts(index int) ([]*wire.TxOut, error) {
+	outputs := builder.InscriptionDataList[index].ExtraRevealOutputs
+	txOuts := make([]*wire.TxOut, 0, len(outputs))
+	for k, output := range outputs {
+		pkScript, err := AddrToPkScript(output.Address, builder.Network)
+		if err != nil {
+			return nil, err
+		}
+		if output.Value <= 0 {
+			return nil, fmt.Errorf("extra reveal output %d of inscription(index %d) value %d is not positive", k, index, output.Value)
+		}
+		if dust := GetDustThreshold(pkScript); output.Value < dust && !builder.AllowDust {
+			return nil, fmt.Errorf("extra reveal output %d of inscription(index %d) value %d is below the dust threshold %d of %s", k, index, output.Value, dust, output.Address)
+		}
+		txOuts = append(txOuts, wire.NewTxOut(output.Value, pkScript))
+	}
+	return txOuts, nil
+}
+
+// extraRevealOutputsValue returns the total value of the extra reveal outputs of data.
+func extraRevealOutputsValue(data InscriptionData) int64 {
+	total := int64(0)
+	for _, output := range data.ExtraRevealOutputs {
+		total += output.Value
+	}
+	return total
+}
+
+// revealPkScript returns the script the inscription at index is revealed to, its RevealPkScript
+// if set or else the script of its RevealAddr. An OP_RETURN script burns the inscription and is
+// only accepted when Burn is set.
+func revealPkScript(network *chaincfg.Params, index int, data InscriptionData) ([]byte, error) {
+	pkScript := data.RevealPkScript
+	if len(pkScript) == 0 {
+		var err error
+		if pkScript, err = AddrToPkScript(data.RevealAddr, network); err != nil {
+			return nil, err
+		}
+	}
+	if pkScript[0] == txscript.OP_RETURN {
+		if !data.Burn {
+			return nil, fmt.Errorf("reveal(index %d) output is an OP_RETURN script which burns the inscription, set Burn to burn it", index)
+		}
+		return pkScript, nil
+	}
+	if txscript.GetScriptClass(pkScript) == txscript.NonStandardTy {
+		return nil, fmt.Errorf("reveal(index %d) pk script is not a standard script", index)
+	}
+	return pkScript, nil
+}
+
+// checkRevealPrevOutputValue makes sure the commit output funding a reveal tx leaves a spendable
+// postage once the reveal fee is paid, which may be dust when allowDust is set.
+func checkRevealPrevOutputValue(index int, prevOutputValue, revealFee int64, pkScript []byte, allowDust bool) error {
+	postage := prevOutputValue - revealFee
+	if postage <= 0 {
+		return fmt.Errorf("reveal(index %d) commit output value %d does not cover the reveal fee %d", index, prevOutputValue, revealFee)
+	}
+	if dust := GetDustThreshold(pkScript); postage < dust && !allowDust {
+		return fmt.Errorf("reveal(index %d) postage %d left after the reveal fee %d is below the dust threshold %d", index, postage, revealFee, dust)
+	}
+	return nil
+}
+
+// buildRequestCommitTx builds the commit tx spending the inputs of request, or the inputs
+// selected among them when SelectInputs is set or they are more than MaxCommitInputs. Inputs
+// are added largest first to the inputs carrying an inscription until they cover the
+// TargetAmount, and the commit private keys are narrowed down to the selected inputs.
+func (builder *InscriptionBuilder) buildRequestCommitTx(request *InscriptionRequest, changePkScript []byte, totalRevealPrevOutputValue, commitFeeRate, minChangeValue int64) error {
+	overLimit := request.MaxCommitInputs > 0 && len(request.CommitTxPrevOutputList) > request.MaxCommitInputs
+	if !request.SelectInputs && !overLimit {
+		return builder.buildCommitTx(request.CommitTxPrevOutputList, changePkScript, totalRevealPrevOutputValue, commitFeeRate, minChangeValue, sacrificeExcessToFee(request))
+	}
+	var selected []int
+	var candidates []int
+	for i, prevOutput := range request.CommitTxPrevOutputList {
+		if prevOutput.HasInscription {
+			selected = append(selected, i)
+		} else {
+			candidates = append(candidates, i)
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return request.CommitTxPrevOutputList[candidates[a]].Amount > request.CommitTxPrevOutputList[candidates[b]].Amount
+	})
+	keys := builder.CommitTxPrivateKeyList
+	multisigKeys := builder.CommitTxMultisigKeyList
+	inscribed := len(selected)
+	first := 1
+	if len(candidates) == 0 {
+		first = 0
+	}
+	var err error
+	for n := first; n <= len(candidates); n++ {
+		selected = append(selected[:inscribed], candidates[:n]...)
+		prevOutputs := make([]*PrevOutput, len(selected))
+		selectedKeys := make([]*btcec.PrivateKey, len(selected))
+		selectedMultisigKeys := make([][]*btcec.PrivateKey, len(selected))
+		for k, i := range selected {
+			prevOutputs[k] = request.CommitTxPrevOutputList[i]
+			selectedKeys[k] = keys[i]
+			selectedMultisigKeys[k] = multisigKeys[i]
+		}
+		builder.CommitTxPrevOutputList = prevOutputs
+		builder.CommitTxPrivateKeyList = selectedKeys
+		builder.CommitTxMultisigKeyList = selectedMultisigKeys
+		builder.CommitTxPrevOutputFetcher = txscript.NewMultiPrevOutFetcher(nil)
+		err = builder.buildCommitTx(prevOutputs, changePkScript, totalRevealPrevOutputValue, commitFeeRate, minChangeValue, sacrificeExcessToFee(request))
+		if !errors.Is(err, ErrInsufficientBalance) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if request.MaxCommitInputs > 0 && len(builder.CommitTxPrevOutputList) > request.MaxCommitInputs {
+		suggestion, err := builder.buildConsolidationSuggestion(builder.CommitTxPrevOutputList, request.ChangeAddress, commitFeeRate, request.MaxCommitInputs)
+		if err != nil {
+			return err
+		}
+		return suggestion
+	}
+	return nil
+}
+
+// TargetAmount returns the amount the commit inputs without an inscription must cover, the
+// value sent to the reveal txs plus the commit fee, or 0 before the commit tx is built.
+func (builder *InscriptionBuilder) TargetAmount() int64 {
+	if builder.CommitTx == nil {
+		return 0
+	}
+	target := int64(0)
+	for _, in := range builder.CommitTx.TxIn {
+		target += builder.CommitTxPrevOutputFetcher.FetchPrevOutput(in.PreviousOutPoint).Value
+	}
+	for _, out := range builder.CommitTx.TxOut {
+		target -= out.Value
+	}
+	counted := make(map[uint32]bool, len(builder.InscriptionTxCtxDataList))
+	for _, ctx := range builder.InscriptionTxCtxDataList {
+		if !counted[ctx.CommitTxOutIndex] {
+			counted[ctx.CommitTxOutIndex] = true
+			target += ctx.RevealTxPrevOutput.Value
+		}
+	}
+	return target
+}
+
+func (builder *InscriptionBuilder) buildCommitTx(commitTxPrevOutputList []*PrevOutput, changePkScript []byte, totalRevealPrevOutputValue, commitFeeRate int64, minChangeValue int64, sacrificeExcessToFee bool) error {
+	totalSenderAmount := btcutil.Amount(0)
+	tx := wire.NewMsgTx(DefaultTxVersion)
+	if !sacrificeExcessToFee {
+		minChangeValue = GetDustThreshold(changePkScript)
+	}
+	for _, prevOutput := range commitTxPrevOutputList {
+		txHash, err := chainhash.NewHashFromStr(prevOutput.TxId)
+		if err != nil {
+			return err
+		}
+		outPoint := wire.NewOutPoint(txHash, prevOutput.VOut)
+		pkScript, err := AddrToPkScript(prevOutput.Address, builder.Network)
+		if err != nil {
+			return err
+		}
+		txOut := wire.NewTxOut(prevOutput.Amount, pkScript)
+		builder.CommitTxPrevOutputFetcher.AddPrevOut(*outPoint, txOut)
+
+		in := wire.NewTxIn(outPoint, nil, nil)
+		in.Sequence = inputSequence(builder.DisableRBF)
+		tx.AddTxIn(in)
+
+		if prevOutput.HasInscription {
+			tx.AddTxOut(wire.NewTxOut(prevOutput.Amount, pkScript))
+			continue
+		}
+		totalSenderAmount += btcutil.Amount(prevOutput.Amount)
+	}
+	preservedOutputs := uint32(len(tx.TxOut))
+	// an aggregated reveal shares one commit output between all of its inscriptions
+	revealTxPrevOutputs := make([]*wire.TxOut, 0, len(builder.InscriptionTxCtxDataList))
+	for _, ctx := range builder.InscriptionTxCtxDataList {
+		k := int(ctx.CommitTxOutIndex - preservedOutputs)
+		for len(revealTxPrevOutputs) <= k {
+			revealTxPrevOutputs = append(revealTxPrevOutputs, nil)
+		}
+		revealTxPrevOutputs[k] = ctx.RevealTxPrevOutput
+	}
+	for _, out := range revealTxPrevOutputs {
+		tx.AddTxOut(out)
+	}
+	if len(builder.CommitOpReturn) > 0 {
+		if len(builder.CommitOpReturn) > txscript.MaxDataCarrierSize {
+			return fmt.Errorf("commit op return data of %d bytes is longer than %d bytes", len(builder.CommitOpReturn), txscript.MaxDataCarrierSize)
+		}
+		opReturnScript, err := txscript.NullDataScript(builder.CommitOpReturn)
+		if err != nil {
+			return err
+		}
+		tx.AddTxOut(wire.NewTxOut(0, opReturnScript))
+	}
+
+	splits, err := builder.changeSplits(changePkScript)
+	if err != nil {
+		return err
+	}
+	changeOutputsAt := len(tx.TxOut)
+	txForEstimate := wire.NewMsgTx(DefaultTxVersion)
+	txForEstimate.TxIn = tx.TxIn
+	var fee, changeAmount btcutil.Amount
+	// the change outputs below their minimum are dropped and the change split again between the
+	// others, with the fee of the smaller tx
+	for len(splits) > 0 {
+		tx.TxOut = tx.TxOut[:changeOutputsAt]
+		for _, split := range splits {
+			tx.AddTxOut(wire.NewTxOut(0, split.pkScript))
+		}
+		txForEstimate.TxOut = tx.TxOut
+		if err := builder.signCommitInputs(txForEstimate, builder.CommitTxPrevOutputFetcher); err != nil {
+			return err
+		}
+
+		fee = btcutil.Amount(applyFeeBuffer(computeFee(GetTransactionWeight(btcutil.NewTx(txForEstimate)), commitFeeRate, builder.FeeRoundingMode), builder.FeeBufferPercent))
+		changeAmount = totalSenderAmount - btcutil.Amount(totalRevealPrevOutputValue) - fee
+		values := splitChange(int64(changeAmount), splits)
+		kept := make([]changeSplit, 0, len(splits))
+		for i, split := range splits {
+			// a change below the dust threshold of its script is not standard even when
+			// MinChangeValue allows it, so it goes to the fee as well
+			if values[i] >= minChangeValue && values[i] >= GetDustThreshold(split.pkScript) {
+				kept = append(kept, split)
+			}
+		}
+		if len(kept) == len(splits) {
+			for i, value := range values {
+				tx.TxOut[changeOutputsAt+i].Value = value
+			}
+			builder.CommitTx = tx
+			return nil
+		}
+		splits = kept
+	}
+	tx.TxOut = tx.TxOut[:changeOutputsAt]
+	if changeAmount < 0 {
+		txForEstimate.TxOut = tx.TxOut
+		feeWithoutChange := btcutil.Amount(applyFeeBuffer(computeFee(GetTransactionWeight(btcutil.NewTx(txForEstimate)), commitFeeRate, builder.FeeRoundingMode), builder.FeeBufferPercent))
+		if totalSenderAmount-btcutil.Amount(totalRevealPrevOutputValue)-feeWithoutChange < 0 {
+			builder.MustCommitTxFee = int64(fee)
+			builder.fundingShortfall = int64(btcutil.Amount(totalRevealPrevOutputValue) + feeWithoutChange - totalSenderAmount)
+			return ErrInsufficientBalance
+		}
+	}
+	builder.CommitTx = tx
+	return nil
+}
+
+type changeSplit struct {
+	pkScript []byte
+	ratio    float64
+}
+
+// changeSplits returns the scripts of the ChangeOutputs with their ratios, or changePkScript
+// taking all the change when there are none.
+func (builder *InscriptionBuilder) changeSplits(changePkScript []byte) ([]changeSplit, error) {
+	if len(builder.ChangeOutputs) == 0 {
+		return []changeSplit{{pkScript: changePkScript, ratio: 1}}, nil
+	}
+	splits := make([]changeSplit, len(builder.ChangeOutputs))
+	for i, output := range builder.ChangeOutputs {
+		if !(output.Ratio > 0) || math.IsInf(output.Ratio, 1) {
+			return nil, fmt.Errorf("ratio %v of change output %d is not a positive number", output.Ratio, i)
+		}
+		pkScript, err := AddrToPkScript(output.Address, builder.Network)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %v", ErrInvalidChangeAddress, output.Address, err)
+		}
+		splits[i] = changeSplit{pkScript: pkScript, ratio: output.Ratio}
+	}
+	return splits, nil
+}
+
+// splitChange splits amount between splits by their ratios, the last one getting the rounding
+// remainder.
+func splitChange(amount int64, splits []changeSplit) []int64 {
+	totalRatio := float64(0)
+	for _, split := range splits {
+		totalRatio += split.ratio
+	}
+	values := make([]int64, len(splits))
+	rest := amount
+	for i := 0; i < len(splits)-1; i++ {
+		values[i] = int64(float64(amount) * splits[i].ratio / totalRatio)
+		rest -= values[i]
+	}
+	values[len(splits)-1] = rest
+	return values
+}
+
+func (builder *InscriptionBuilder) completeRevealTx(ctx context.Context) error {
+	if err := builder.checkRevealPrevOutputs(); err != nil {
+		return err
+	}
+	builder.linkRevealTx()
+	if err := builder.signRevealParents(); err != nil {
+		return err
+	}
+	sigHashes, ctxList, err := builder.revealSigHashes()
+	if err != nil {
+		return err
+	}
+	var signOptions []schnorr.SignOption
+	if builder.Deterministic {
+		signOptions = append(signOptions, schnorr.CustomNonce([32]byte{}))
+	}
+	signatures := make([][]byte, len(sigHashes))
+	for i, sigHash := range sigHashes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		signature, err := schnorr.Sign(ctxList[i].PrivateKey, sigHash, signOptions...)
+		if err != nil {
+			return err
+		}
+		signatures[i] = signature.Serialize()
+	}
+	return builder.ApplyRevealSignatures(signatures)
+}
+
+// checkRevealPrevOutputs checks that every commit output spent by a reveal tx has the value and
+// script the reveal fee was computed for.
+func (builder *InscriptionBuilder) checkRevealPrevOutputs() error {
+	for i, ctx := range builder.InscriptionTxCtxDataList {
+		if int(ctx.CommitTxOutIndex) >= len(builder.CommitTx.TxOut) {
+			return fmt.Errorf("commit tx has no output %d for reveal(index %d)", ctx.CommitTxOutIndex, i)
+		}
+		out := builder.CommitTx.TxOut[ctx.CommitTxOutIndex]
+		if out.Value != ctx.RevealTxPrevOutput.Value || !bytes.Equal(out.PkScript, ctx.RevealTxPrevOutput.PkScript) {
+			return fmt.Errorf("commit tx output %d (value %d) does not match the prev output of reveal(index %d) (value %d)", ctx.CommitTxOutIndex, out.Value, i, ctx.RevealTxPrevOutput.Value)
+		}
+	}
+	return nil
+}
+
+// linkRevealTx points every reveal input but the parent inputs to the commit tx and registers
+// the commit and parent outputs as the reveal prev outputs.
+func (builder *InscriptionBuilder) linkRevealTx() {
+	commitTxHash := builder.CommitTx.TxHash()
+	for _, ctx := range builder.InscriptionTxCtxDataList {
+		builder.RevealTxPrevOutputFetcher.AddPrevOut(wire.OutPoint{
+			Hash:  commitTxHash,
+			Index: ctx.CommitTxOutIndex,
+		}, ctx.RevealTxPrevOutput)
+		if ctx.ParentPrevOutput != nil {
+			builder.RevealTxPrevOutputFetcher.AddPrevOut(*ctx.ParentOutPoint, ctx.ParentPrevOutput)
+		}
+	}
+	for _, revealTx := range builder.RevealTx {
+		for _, in := range revealTx.TxIn {
+			if !builder.isParentInput(in) {
+				in.PreviousOutPoint.Hash = commitTxHash
+			}
+		}
+	}
+}
+
+// inscriptionIndexesOf returns the indexes in InscriptionDataList of the inscriptions revealed
+// by the reveal input in, several for an aggregated reveal and none for a parent input.
+func (builder *InscriptionBuilder) inscriptionIndexesOf(in *wire.TxIn) []int {
+	if builder.isParentInput(in) {
+		return nil
+	}
+	var indexes []int
+	for i, ctx := range builder.InscriptionTxCtxDataList {
+		if ctx.CommitTxOutIndex == in.PreviousOutPoint.Index {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// isParentInput reports whether in spends the parent of one of the inscriptions.
+func (builder *InscriptionBuilder) isParentInput(in *wire.TxIn) bool {
+	for _, ctx := range builder.InscriptionTxCtxDataList {
+		if ctx.ParentOutPoint != nil && in.PreviousOutPoint == *ctx.ParentOutPoint {
+			return true
+		}
+	}
+	return false
+}
+
+// signRevealParents signs the parent input of every reveal tx inscribing a child. The reveal
+// txs must have been linked to the commit tx.
+func (builder *InscriptionBuilder) signRevealParents() error {
+	for i, revealTx := range builder.RevealTx {
+		var txSigHashes *txscript.TxSigHashes
+		for j, in := range revealTx.TxIn {
+			for _, ctx := range builder.InscriptionTxCtxDataList {
+				if ctx.ParentOutPoint == nil || in.PreviousOutPoint != *ctx.ParentOutPoint {
+					continue
+				}
+				if txSigHashes == nil {
+					txSigHashes = builder.SigHashCache.txSigHashes(revealTx, builder.RevealTxPrevOutputFetcher)
+				}
+				if err := SignTxInput1(ctx.ParentPrivateKey, revealTx, j, txSigHashes, ctx.ParentPrevOutput.PkScript, ctx.ParentPrevOutput.Value); err != nil {
+					return fmt.Errorf("reveal(index %d) parent input %d sign error: %w", i, j, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// revealSigHashes returns the tapscript sighash of every reveal input, reveal by reveal and
+// input by input, along with the inscription ctx whose key must sign it. The reveal txs must
+// have been linked to the commit tx. The sighash midstate of a reveal tx is computed once and
+// shared by all of its inputs, which matters for batch reveals spending many commit outputs.
+func (builder *InscriptionBuilder) revealSigHashes() ([][]byte, []*inscriptionTxCtxData, error) {
+	ctxByCommitTxOutIndex := make(map[uint32]*inscriptionTxCtxData, len(builder.InscriptionTxCtxDataList))
+	for _, ctx := range builder.InscriptionTxCtxDataList {
+		ctxByCommitTxOutIndex[ctx.CommitTxOutIndex] = ctx
+	}
+	var sigHashes [][]byte
+	var ctxList []*inscriptionTxCtxData
+	// every input of a reveal tx but a parent input spends a commit output, sign each of them
+	// with the tapscript of the inscription committed in that output
+	for i, revealTx := range builder.RevealTx {
+		txSigHashes := builder.SigHashCache.txSigHashes(revealTx, builder.RevealTxPrevOutputFetcher)
+		for j, in := range revealTx.TxIn {
+			if builder.isParentInput(in) {
+				continue
+			}
+			ctx, ok := ctxByCommitTxOutIndex[in.PreviousOutPoint.Index]
+			if !ok {
+				return nil, nil, fmt.Errorf("reveal(index %d) input %d does not spend an inscription commit output", i, j)
+			}
+			var sigHashOptions []txscript.TaprootSigHashOption
+			if len(ctx.Annex) > 0 {
+				sigHashOptions = append(sigHashOptions, txscript.WithAnnex(ctx.Annex))
+			}
+			sigHash, err := txscript.CalcTapscriptSignaturehash(txSigHashes, txscript.SigHashDefault, revealTx, j,
+				builder.RevealTxPrevOutputFetcher, txscript.NewBaseTapLeaf(ctx.InscriptionScript), sigHashOptions...)
+			if err != nil {
+				return nil, nil, err
+			}
+			sigHashes = append(sigHashes, sigHash)
+			ctxList = append(ctxList, ctx)
+		}
+	}
+	return sigHashes, ctxList, nil
+}
+
+// SigHashCache keeps the sighash midstates of reveal txs, keyed by the tx without witness and
+// the outputs it spends, so that signing the same reveal tx again does not compute them again.
+// It is safe for concurrent use.
+type SigHashCache struct {
+	mu     sync.Mutex
+	hashes map[[sha256.Size]byte]*txscript.TxSigHashes
+}
+
+func NewSigHashCache() *SigHashCache {
+	return &SigHashCache{hashes: make(map[[sha256.Size]byte]*txscript.TxSigHashes)}
+}
+
+// txSigHashes returns the cached sighash midstate of tx, computing it on a miss. A nil cache
+// always computes it.
+func (c *SigHashCache) txSigHashes(tx *wire.MsgTx, prevOutFetcher txscript.PrevOutputFetcher) *txscript.TxSigHashes {
+	if c == nil {
+		return txscript.NewTxSigHashes(tx, prevOutFetcher)
+	}
+	var buf bytes.Buffer
+	if err := tx.SerializeNoWitness(&buf); err != nil {
+		return txscript.NewTxSigHashes(tx, prevOutFetcher)
+	}
+	for _, in := range tx.TxIn {
+		if prevOut := prevOutFetcher.FetchPrevOutput(in.PreviousOutPoint); prevOut != nil {
+			if err := wire.WriteTxOut(&buf, 0, 0, prevOut); err != nil {
+				return txscript.NewTxSigHashes(tx, prevOutFetcher)
+			}
+		}
+	}
+	key := sha256.Sum256(buf.Bytes())
+
+	c.mu.Lock()
+	txSigHashes, ok := c.hashes[key]
+	c.mu.Unlock()
+	if ok {
+		return txSigHashes
+	}
+	txSigHashes = txscript.NewTxSigHashes(tx, prevOutFetcher)
+	c.mu.Lock()
+	c.hashes[key] = txSigHashes
+	c.mu.Unlock()
+	return txSigHashes
+}
+
+// RevealSigHashes returns the tapscript sighashes of the reveal inputs without signing them,
+// reveal by reveal and input by input, so they can be signed by an external signer such as a
+// hardware wallet. A reveal tx spending a single commit output has a single sighash, parent
+// inputs are signed by the builder and have none.
+func (builder *InscriptionBuilder) RevealSigHashes() ([][]byte, error) {
+	sigHashes, _, err := builder.revealSigHashes()
+	return sigHashes, err
+}
+
+// GetRevealSigHashes returns the sighashes of RevealSigHashes hex encoded.
+func (builder *InscriptionBuilder) GetRevealSigHashes() ([]string, error) {
+	sigHashes, err := builder.RevealSigHashes()
+	if err != nil {
+		return nil, err
+	}
+	sigHashList := make([]string, len(sigHashes))
+	for i, sigHash := range sigHashes {
+		sigHashList[i] = hex.EncodeToString(sigHash)
+	}
+	return sigHashList, nil
+}
+
+// SetRevealSignatures applies the hex encoded schnorr signatures of the sighashes returned by
+// GetRevealSigHashes, see ApplyRevealSignatures.
+func (builder *InscriptionBuilder) SetRevealSignatures(sigs []string) error {
+	signatures := make([][]byte, len(sigs))
+	for i, sig := range sigs {
+		signature, err := hex.DecodeString(sig)
+		if err != nil {
+			return fmt.Errorf("reveal signature %d is not hex: %w", i, err)
+		}
+		signatures[i] = signature
+	}
+	return builder.ApplyRevealSignatures(signatures)
+}
+
+// ApplyRevealSignatures sets the witness of every reveal input from the 64 bytes schnorr
+// signatures of the sighashes returned by RevealSigHashes, in the same order.
+func (builder *InscriptionBuilder) ApplyRevealSignatures(sigs [][]byte) error {
+	sigHashes, ctxList, err := builder.revealSigHashes()
+	if err != nil {
+		return err
+	}
+	if len(sigs) != len(sigHashes) {
+		return fmt.Errorf("got %d reveal signatures for %d reveal inputs", len(sigs), len(sigHashes))
+	}
+	k := 0
+	for i, revealTx := range builder.RevealTx {
+		for j, in := range revealTx.TxIn {
+			if builder.isParentInput(in) {
+				continue
+			}
+			ctx := ctxList[k]
+			signature, err := schnorr.ParseSignature(sigs[k])
+			if err != nil {
+				return fmt.Errorf("reveal(index %d) input %d signature error: %w", i, j, err)
+			}
+			internalKey, err := ctx.internalKey()
+			if err != nil {
+				return err
+			}
+			if !signature.Verify(sigHashes[k], internalKey) {
+				return fmt.Errorf("reveal(index %d) input %d signature is invalid", i, j)
+			}
+			in.Witness = ctx.revealWitness(sigs[k], ctx.ControlBlockWitness)
+			k++
+		}
+	}
+	// check tx max tx wight
+	for i, tx := range builder.RevealTx {
+		revealWeight := GetTransactionWeight(btcutil.NewTx(tx))
+		if revealWeight > MaxStandardTxWeight {
+			return fmt.Errorf("%w: reveal(index %d) weight %d is greater than %d", ErrRevealWeightExceeded, i, revealWeight, MaxStandardTxWeight)
+		}
+	}
+	return nil
+}
+
+func (builder *InscriptionBuilder) signCommitTx() error {
+	return builder.signCommitInputs(builder.CommitTx, builder.CommitTxPrevOutputFetcher)
+}
+
+// signCommitInputs signs every input of tx, which spends the CommitTxPrevOutputList in order,
+// with CommitSigHashType. Taproot inputs with a TapMerkleRoot are spent by key path with the key
+// tweaked by their merkle root, P2WSH multisig inputs with their CommitTxMultisigKeyList.
+func (builder *InscriptionBuilder) signCommitInputs(tx *wire.MsgTx, prevOutFetcher *txscript.MultiPrevOutFetcher) error {
+	txSigHashes := txscript.NewTxSigHashes(tx, prevOutFetcher)
+	for i, in := range tx.TxIn {
+		prevOut := prevOutFetcher.FetchPrevOutput(in.PreviousOutPoint)
+		if redeemScript := builder.CommitTxPrevOutputList[i].RedeemScript; len(redeemScript) > 0 {
+			var keys []*btcec.PrivateKey
+			if i < len(builder.CommitTxMultisigKeyList) {
+				keys = builder.CommitTxMultisigKeyList[i]
+			}
+			if err := signMultisigInput(tx, i, txSigHashes, prevOut.Value, redeemScript, keys, builder.CommitSigHashType); err != nil {
+				return err
+			}
+			continue
+		}
+		if merkleRoot := builder.CommitTxPrevOutputList[i].TapMerkleRoot; len(merkleRoot) > 0 {
+			signature, err := txscript.RawTxInTaprootSignature(tx, txSigHashes, i, prevOut.Value, prevOut.PkScript, merkleRoot,
+				builder.CommitSigHashType, builder.CommitTxPrivateKeyList[i])
+			if err != nil {
+				return err
+			}
+			tx.TxIn[i].Witness = wire.TxWitness{withTaprootSigHashType(signature, builder.CommitSigHashType)}
+			continue
+		}
+		if err := SignTxInputWithSigHashType(builder.CommitTxPrivateKeyList[i], tx, i, txSigHashes, prevOut.PkScript, prevOut.Value, builder.CommitSigHashType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withTaprootSigHashType appends hashType to a 64 byte schnorr signature unless it is
+// SigHashDefault. btcd v0.23 leaves it off for every hash type, so such signatures would be
+// verified as SigHashDefault and rejected.
+func withTaprootSigHashType(signature []byte, hashType txscript.SigHashType) []byte {
+	if hashType == txscript.SigHashDefault || len(signature) != schnorr.SignatureSize {
+		return signature
+	}
+	return append(signature, byte(hashType))
+}
+
+func SignTxInput1(privateKey *btcec.PrivateKey, tx *wire.MsgTx, index int, txSigHashes *txscript.TxSigHashes,
+	pkScript []byte, amount int64) error {
+	return SignTxInputWithSigHashType(privateKey, tx, index, txSigHashes, pkScript, amount, 0)
+}
+
+// SignTxInputWithSigHashType signs the input at index like SignTxInput1, with hashType unless it
+// is 0, in which case taproot inputs are signed with SigHashDefault and the others with SigHashAll.
+func SignTxInputWithSigHashType(privateKey *btcec.PrivateKey, tx *wire.MsgTx, index int, txSigHashes *txscript.TxSigHashes,
+	pkScript []byte, amount int64, hashType txscript.SigHashType) error {
+	if txscript.IsPayToTaproot(pkScript) {
+		witness, err := txscript.TaprootWitnessSignature(tx, txSigHashes, index, amount, pkScript, hashType, privateKey)
+		if err != nil {
+			return err
+		}
+		witness[0] = withTaprootSigHashType(witness[0], hashType)
+
+		tx.TxIn[index].Witness = witness
+
+		return nil
+	}
+
+	if hashType == 0 {
+		hashType = txscript.SigHashAll
+	}
+	if txscript.IsPayToPubKeyHash(pkScript) {
+		sigScript, err := txscript.SignatureScript(tx, index, pkScript, hashType, privateKey, true)
+		if err != nil {
+			return err
+		}
+
+		tx.TxIn[index].SignatureScript = sigScript
+
+		return nil
+	}
+
+	pubKeyBytes := privateKey.PubKey().SerializeCompressed()
+	script, err := PayToPubKeyHashScript(btcutil.Hash160(pubKeyBytes))
+	if err != nil {
+		return err
+	}
+
+	witness, err := txscript.WitnessSignature(tx, txSigHashes, index, amount, script, hashType, privateKey, true)
+	if err != nil {
+		return err
+	}
+	tx.TxIn[index].Witness = witness
+
+	if !txscript.IsPayToScriptHash(pkScript) {
+		return nil
+	}
+
+	redeemScript, err := PayToWitnessPubKeyHashScript(btcutil.Hash160(pubKeyBytes))
+	if err != nil {
+		return err
+	}
+
+	tx.TxIn[index].SignatureScript = append([]byte{byte(len(redeemScript))}, redeemScript...)
+
+	return nil
+}
+
+func Sign(tx *wire.MsgTx, privateKeys []*btcec.PrivateKey, prevOutFetcher *txscript.MultiPrevOutFetcher) error {
+	return SignWithSigHashType(tx, privateKeys, prevOutFetcher, 0)
+}
+
+// SignWithSigHashType signs every input of tx like Sign, with hashType unless it is 0.
+func SignWithSigHashType(tx *wire.MsgTx, privateKeys []*btcec.PrivateKey, prevOutFetcher *txscript.MultiPrevOutFetcher, hashType txscript.SigHashType) error {
+	txSigHashes := txscript.NewTxSigHashes(tx, prevOutFetcher)
+
+	for i, in := range tx.TxIn {
+		prevOut := prevOutFetcher.FetchPrevOutput(in.PreviousOutPoint)
+		err := SignTxInputWithSigHashType(privateKeys[i], tx, i, txSigHashes, prevOut.PkScript, prevOut.Value, hashType)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func GetTxHex(tx *wire.MsgTx) (string, error) {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+func (builder *InscriptionBuilder) GetCommitTxHex() (string, error) {
+	return GetTxHex(builder.CommitTx)
+}
+
+func (builder *InscriptionBuilder) GetRevealTxHexList() ([]string, error) {
+	txHexList := make([]string, len(builder.RevealTx))
+	for i := range builder.RevealTx {
+		for j, in := range builder.RevealTx[i].TxIn {
+			if in.PreviousOutPoint.Hash == (chainhash.Hash{}) && !builder.isParentInput(in) {
+				return nil, fmt.Errorf("%w: reveal(index %d) input %d spends a null commit txid", ErrRevealNotLinked, i, j)
+			}
+		}
+		txHex, err := GetTxHex(builder.RevealTx[i])
+		if err != nil {
+			return nil, err
+		}
+		txHexList[i] = txHex
+	}
+	return txHexList, nil
+}
+
+func (builder *InscriptionBuilder) CalculateFee() (int64, []int64) {
+	commitTxFee := int64(0)
+	for _, in := range builder.CommitTx.TxIn {
+		commitTxFee += builder.CommitTxPrevOutputFetcher.FetchPrevOutput(in.PreviousOutPoint).Value
+	}
+	for _, out := range builder.CommitTx.TxOut {
+		commitTxFee -= out.Value
+	}
+	// a reveal tx may spend several commit and parent outputs, its fee is all of its inputs
+	// minus all of its outputs
+	revealTxFees := make([]int64, 0, len(builder.RevealTx))
+	for _, tx := range builder.RevealTx {
+		revealTxFee := int64(0)
+		for _, in := range tx.TxIn {
+			revealTxFee += builder.RevealTxPrevOutputFetcher.FetchPrevOutput(in.PreviousOutPoint).Value
+		}
+		for _, out := range tx.TxOut {
+			revealTxFee -= out.Value
+		}
+		revealTxFees = append(revealTxFees, revealTxFee)
+	}
+	return commitTxFee, revealTxFees
+}
+
+// CommitTotalInput returns the total value of the outputs spent by the commit tx.
+func (builder *InscriptionBuilder) CommitTotalInput() int64 {
+	total := int64(0)
+	for _, in := range builder.CommitTx.TxIn {
+		total += builder.CommitTxPrevOutputFetcher.FetchPrevOutput(in.PreviousOutPoint).Value
+	}
+	return total
+}
+
+// CommitTotalOutput returns the total value of the commit tx outputs, change included.
+func (builder *InscriptionBuilder) CommitTotalOutput() int64 {
+	total := int64(0)
+	for _, out := range builder.CommitTx.TxOut {
+		total += out.Value
+	}
+	return total
+}
+
+// TotalInputValue returns the value of the commit inputs which do not carry an inscription.
+func (builder *InscriptionBuilder) TotalInputValue() int64 {
+	total := int64(0)
+	for _, prevOutput := range builder.CommitTxPrevOutputList {
+		if !prevOutput.HasInscription {
+			total += prevOutput.Amount
+		}
+	}
+	return total
+}
+
+// TotalPostage returns the value of the reveal outputs holding the new inscriptions and of
+// their extra reveal outputs, the outputs sending the parents back excluded.
+func (builder *InscriptionBuilder) TotalPostage() int64 {
+	total := int64(0)
+	for _, tx := range builder.RevealTx {
+		for _, out := range tx.TxOut {
+			total += out.Value
+		}
+	}
+	for _, ctx := range builder.InscriptionTxCtxDataList {
+		if ctx.ParentPrevOutput != nil {
+			total -= ctx.ParentPrevOutput.Value
+		}
+	}
+	return total
+}
+
+// ChangeValue returns the value the commit tx sends back to the change address, 0 without
+// a change output.
+func (builder *InscriptionBuilder) ChangeValue() int64 {
+	revealOutputs := make(map[uint32]bool, len(builder.InscriptionTxCtxDataList))
+	for _, ctx := range builder.InscriptionTxCtxDataList {
+		revealOutputs[ctx.CommitTxOutIndex] = true
+	}
+	total := int64(0)
+	for i := inscribedInputCount(builder.CommitTxPrevOutputList); int(i) < len(builder.CommitTx.TxOut); i++ {
+		if !revealOutputs[i] {
+			total += builder.CommitTx.TxOut[i].Value
+		}
+	}
+	return total
+}
+
+// GetDustThreshold returns the minimum value an output paying to pkScript must carry to
+// be relayed, using bitcoin core's default dust relay fee of 3 sat/vB.
+// Unspendable (OP_RETURN) outputs have no dust threshold.
+func GetDustThreshold(pkScript []byte) int64 {
+	if len(pkScript) > 0 && pkScript[0] == txscript.OP_RETURN {
+		return 0
+	}
+	// the size of the output itself plus the size of the input that will spend it
+	size := int64(wire.NewTxOut(0, pkScript).SerializeSize())
+	if txscript.IsWitnessProgram(pkScript) {
+		// outpoint + empty script sig + sequence + witness discounted p2wpkh spend
+		size += 32 + 4 + 1 + (107 / WitnessScaleFactor) + 4
+	} else {
+		size += 32 + 4 + 1 + 107 + 4
+	}
+	return size * DustRelayFeeRate
+}
+
+// Warnings returns the problems found in the request which did not prevent building the txs
+// but should be reviewed before broadcasting them.
+func (builder *InscriptionBuilder) Warnings() []string {
+	return append([]string(nil), builder.warnings...)
+}
+
+// InscriptionIDs returns the ids the inscriptions will get once the reveal txs are mined,
+// in the order of InscriptionDataList. The inscriptions of a reveal tx are numbered in the
+// order of their inputs, then of their envelopes within an input.
+func (builder *InscriptionBuilder) InscriptionIDs() []string {
+	ids := make([]string, len(builder.InscriptionTxCtxDataList))
+	for _, tx := range builder.RevealTx {
+		n := 0
+		for _, in := range tx.TxIn {
+			for _, i := range builder.inscriptionIndexesOf(in) {
+				ids[i] = fmt.Sprintf("%si%d", tx.TxHash().String(), n)
+				n++
+			}
+		}
+	}
+	return ids
+}
+
+// RevealsByInscriptionID maps the predicted id of every inscription to the hex of its reveal tx.
+func (builder *InscriptionBuilder) RevealsByInscriptionID() (map[string]string, error) {
+	ids := builder.InscriptionIDs()
+	reveals := make(map[string]string, len(ids))
+	for _, tx := range builder.RevealTx {
+		txHex, err := GetTxHex(tx)
+		if err != nil {
+			return nil, err
+		}
+		for _, in := range tx.TxIn {
+			for _, i := range builder.inscriptionIndexesOf(in) {
+				reveals[ids[i]] = txHex
+			}
+		}
+	}
+	return reveals, nil
+}
+
+type InscriptionManifestItem struct {
+	Index         int    `json:"index"`
+	InscriptionId string `json:"inscriptionId"`
+	ContentType   string `json:"contentType"`
+	Sha256        string `json:"sha256"`
+}
+
+// BuildManifest returns a json manifest of the inscriptions with their predicted ids and the
+// sha256 of their bodies, e.g. for the metadata file of a collection.
+func (builder *InscriptionBuilder) BuildManifest() ([]byte, error) {
+	ids := builder.InscriptionIDs()
+	if len(ids) != len(builder.InscriptionDataList) {
+		return nil, errors.New("reveal txs do not match inscription data list")
+	}
+	manifest := make([]InscriptionManifestItem, len(ids))
+	for i, data := range builder.InscriptionDataList {
+		bodyHash := sha256.Sum256(data.Body)
+		manifest[i] = InscriptionManifestItem{
+			Index:         i,
+			InscriptionId: ids[i],
+			ContentType:   data.ContentType,
+			Sha256:        hex.EncodeToString(bodyHash[:]),
+		}
+	}
+	return json.Marshal(manifest)
+}
+
+// TotalVSize returns the sum of the virtual sizes of the commit tx and all reveal txs,
+// i.e. the block space occupied by the whole inscription package.
+func (builder *InscriptionBuilder) TotalVSize() int64 {
+	totalVSize := int64(0)
+	if builder.CommitTx != nil {
+		totalVSize += GetTxVirtualSize(btcutil.NewTx(builder.CommitTx))
+	}
+	for _, tx := range builder.RevealTx {
+		totalVSize += GetTxVirtualSize(btcutil.NewTx(tx))
+	}
+	return totalVSize
+}
+
+// DependencyGraph maps the txid of every tx of the package to the txids of the package txs it spends,
+// the commit tx has no parents and every reveal tx depends on the commit tx.
+func (builder *InscriptionBuilder) DependencyGraph() map[string][]string {
+	txs := make([]*wire.MsgTx, 0, len(builder.RevealTx)+1)
+	if builder.CommitTx != nil {
+		txs = append(txs, builder.CommitTx)
+	}
+	txs = append(txs, builder.RevealTx...)
+
+	graph := make(map[string][]string, len(txs))
+	for _, tx := range txs {
+		graph[tx.TxHash().String()] = []string{}
+	}
+	for _, tx := range txs {
+		txId := tx.TxHash().String()
+		for _, in := range tx.TxIn {
+			parentTxId := in.PreviousOutPoint.Hash.String()
+			if _, ok := graph[parentTxId]; !ok || parentTxId == txId {
+				continue
+			}
+			known := false
+			for _, id := range graph[txId] {
+				if id == parentTxId {
+					known = true
+					break
+				}
+			}
+			if !known {
+				graph[txId] = append(graph[txId], parentTxId)
+			}
+		}
+	}
+	return graph
+}
+
+// SimulateAcceptance runs the checks of a mempool on the commit and reveal txs before they are
+// broadcast: every tx must be within the standard weight, pay only standard non dust outputs,
+// and every input must satisfy the script of the output it spends. The first failure is returned.
+func (builder *InscriptionBuilder) SimulateAcceptance() error {
+	if builder.CommitTx == nil {
+		return errors.New("commit tx is not built")
+	}
+	if err := checkTxAcceptance(builder.CommitTx, builder.CommitTxPrevOutputFetcher); err != nil {
+		return fmt.Errorf("commit tx %w", err)
+	}
+	for i, tx := range builder.RevealTx {
+		if err := checkTxAcceptance(tx, builder.RevealTxPrevOutputFetcher); err != nil {
+			return fmt.Errorf("reveal(index %d) tx %w", i, err)
+		}
+	}
+	return nil
+}
+
+func checkTxAcceptance(tx *wire.MsgTx, prevOutFetcher txscript.PrevOutputFetcher) error {
+	if weight := GetTransactionWeight(btcutil.NewTx(tx)); weight > MaxStandardTxWeight {
+		return fmt.Errorf("weight %d is greater than %d (MAX_STANDARD_TX_WEIGHT)", weight, MaxStandardTxWeight)
+	}
+	for i, out := range tx.TxOut {
+		if txscript.GetScriptClass(out.PkScript) == txscript.NonStandardTy {
+			return fmt.Errorf("output %d script is not standard", i)
+		}
+		if dust := GetDustThreshold(out.PkScript); out.Value < dust {
+			return fmt.Errorf("output %d value %d is below the dust threshold %d", i, out.Value, dust)
+		}
 	}
-	for i := range builder.InscriptionTxCtxDataList {
-		revealTx := builder.RevealTx[i]
-		witnessArray, err := txscript.CalcTapscriptSignaturehash(txscript.NewTxSigHashes(revealTx, builder.RevealTxPrevOutputFetcher),
-			txscript.SigHashDefault, revealTx, 0, builder.RevealTxPrevOutputFetcher, txscript.NewBaseTapLeaf(builder.InscriptionTxCtxDataList[i].InscriptionScript))
-		if err != nil {
-			return err
+	txSigHashes := txscript.NewTxSigHashes(tx, prevOutFetcher)
+	for i, in := range tx.TxIn {
+		prevOut := prevOutFetcher.FetchPrevOutput(in.PreviousOutPoint)
+		if prevOut == nil {
+			return fmt.Errorf("input %d spends unknown output %s", i, in.PreviousOutPoint)
 		}
-		signature, err := schnorr.Sign(builder.InscriptionTxCtxDataList[i].PrivateKey, witnessArray)
+		vm, err := txscript.NewEngine(prevOut.PkScript, tx, i, txscript.StandardVerifyFlags, nil, txSigHashes, prevOut.Value, prevOutFetcher)
 		if err != nil {
-			return err
+			return fmt.Errorf("input %d error: %w", i, err)
 		}
-		witness := wire.TxWitness{signature.Serialize(), builder.InscriptionTxCtxDataList[i].InscriptionScript, builder.InscriptionTxCtxDataList[i].ControlBlockWitness}
-		builder.RevealTx[i].TxIn[0].Witness = witness
-	}
-	// check tx max tx wight
-	for i, tx := range builder.RevealTx {
-		revealWeight := GetTransactionWeight(btcutil.NewTx(tx))
-		if revealWeight > MaxStandardTxWeight {
-			return errors.New(fmt.Sprintf("reveal(index %d) transaction weight greater than %d (MAX_STANDARD_TX_WEIGHT): %d", i, MaxStandardTxWeight, revealWeight))
+		if err := vm.Execute(); err != nil {
+			return fmt.Errorf("input %d script error: %w", i, err)
 		}
 	}
 	return nil
 }
 
-func (builder *InscriptionBuilder) signCommitTx() error {
-	return Sign(builder.CommitTx, builder.CommitTxPrivateKeyList, builder.CommitTxPrevOutputFetcher)
+// RevealRateDeviation returns, for every reveal tx, its actual fee rate in sat/vB minus the
+// requested one. Rounding keeps it under 1 sat/vB, a larger deviation means the reveal was
+// sized wrong.
+func (builder *InscriptionBuilder) RevealRateDeviation() []float64 {
+	deviations := make([]float64, len(builder.RevealTx))
+	_, revealTxFees := builder.CalculateFee()
+	for i, tx := range builder.RevealTx {
+		vSize := GetTxVirtualSize(btcutil.NewTx(tx))
+		deviations[i] = float64(revealTxFees[i])/float64(vSize) - float64(builder.RevealFeeRates[i])
+	}
+	return deviations
 }
 
-func SignTxInput1(privateKey *btcec.PrivateKey, tx *wire.MsgTx, index int, txSigHashes *txscript.TxSigHashes,
-	pkScript []byte, amount int64) error {
-	if txscript.IsPayToTaproot(pkScript) {
-		witness, err := txscript.TaprootWitnessSignature(tx, txSigHashes, index, amount, pkScript, txscript.SigHashDefault, privateKey)
-		if err != nil {
-			return err
+// TapMerkleRoots returns the taproot merkle root of the commit output of every inscription,
+// tweaking the reveal internal key with it gives the output key of the commit address.
+func (builder *InscriptionBuilder) TapMerkleRoots() [][]byte {
+	roots := make([][]byte, len(builder.InscriptionTxCtxDataList))
+	for i, ctx := range builder.InscriptionTxCtxDataList {
+		roots[i] = append([]byte(nil), ctx.TapMerkleRoot...)
+	}
+	return roots
+}
+
+// CommitOutputAddresses returns the address of every commit tx output by output index, that is
+// the outputs preserving inscribed inputs, then the commit address of every inscription at its
+// CommitTxOutIndex and finally the change. Outputs without an address map to an empty string.
+func (builder *InscriptionBuilder) CommitOutputAddresses() []string {
+	addrs := make([]string, len(builder.CommitTx.TxOut))
+	for i, out := range builder.CommitTx.TxOut {
+		_, outAddrs, _, err := txscript.ExtractPkScriptAddrs(out.PkScript, builder.Network)
+		if err == nil && len(outAddrs) == 1 {
+			addrs[i] = outAddrs[0].EncodeAddress()
 		}
+	}
+	return addrs
+}
 
-		tx.TxIn[index].Witness = witness
+// InscriptionPackagePSBT bundles the hex encoded unsigned PSBTs of a commit tx and of its reveal
+// txs, to be signed offline together.
+type InscriptionPackagePSBT struct {
+	CommitPSBT  string   `json:"commitPsbt"`
+	RevealPSBTs []string `json:"revealPsbts"`
+}
 
-		return nil
+// PackagePSBT returns the JSON encoded InscriptionPackagePSBT of the commit and reveal txs. The
+// commit inputs carry their witness utxo, plus their redeem script for p2sh-p2wpkh, and the
+// reveal inputs carry the commit output, the inscription tapscript with its control block and
+// the internal key. The reveal txs spend the commit tx as built, so re-signing commit inputs
+// which are not segwit changes the commit txid they refer to.
+func (builder *InscriptionBuilder) PackagePSBT() (string, error) {
+	commitPSBT, err := builder.commitPSBT()
+	if err != nil {
+		return "", err
 	}
-
-	if txscript.IsPayToPubKeyHash(pkScript) {
-		sigScript, err := txscript.SignatureScript(tx, index, pkScript, txscript.SigHashAll, privateKey, true)
+	pkg := InscriptionPackagePSBT{RevealPSBTs: make([]string, len(builder.RevealTx))}
+	if pkg.CommitPSBT, err = encodePSBT(commitPSBT); err != nil {
+		return "", err
+	}
+	for i, revealTx := range builder.RevealTx {
+		revealPSBT, err := unsignedPSBT(revealTx)
 		if err != nil {
-			return err
+			return "", err
+		}
+		for j, in := range revealTx.TxIn {
+			revealPSBT.Inputs[j].WitnessUtxo = builder.RevealTxPrevOutputFetcher.FetchPrevOutput(in.PreviousOutPoint)
+			indexes := builder.inscriptionIndexesOf(in)
+			if len(indexes) == 0 {
+				continue
+			}
+			ctx := builder.InscriptionTxCtxDataList[indexes[0]]
+			internalKey, err := ctx.internalKey()
+			if err != nil {
+				return "", err
+			}
+			revealPSBT.Inputs[j].TaprootInternalKey = schnorr.SerializePubKey(internalKey)
+			revealPSBT.Inputs[j].TaprootLeafScript = []*psbt.TaprootTapLeafScript{{
+				ControlBlock: ctx.ControlBlockWitness,
+				Script:       ctx.InscriptionScript,
+				LeafVersion:  txscript.BaseLeafVersion,
+			}}
+		}
+		if pkg.RevealPSBTs[i], err = encodePSBT(revealPSBT); err != nil {
+			return "", err
 		}
-
-		tx.TxIn[index].SignatureScript = sigScript
-
-		return nil
 	}
-
-	pubKeyBytes := privateKey.PubKey().SerializeCompressed()
-	script, err := PayToPubKeyHashScript(btcutil.Hash160(pubKeyBytes))
+	bundle, err := json.Marshal(pkg)
 	if err != nil {
-		return err
+		return "", err
 	}
+	return string(bundle), nil
+}
 
-	witness, err := txscript.WitnessSignature(tx, txSigHashes, index, amount, script, txscript.SigHashAll, privateKey, true)
+// GetCommitTxPSBT returns the unsigned commit tx as a base64 PSBT whose inputs carry the
+// outputs they spend as witness utxos, for wallets signing PSBTs only.
+func (builder *InscriptionBuilder) GetCommitTxPSBT() (string, error) {
+	commitPSBT, err := builder.commitPSBT()
 	if err != nil {
-		return err
+		return "", err
 	}
-	tx.TxIn[index].Witness = witness
+	return commitPSBT.B64Encode()
+}
 
-	if !txscript.IsPayToScriptHash(pkScript) {
-		return nil
+// commitPSBT returns the unsigned PSBT of the commit tx with the witness utxo of every input,
+// the redeem script of the nested segwit ones and the witness script of the multisig ones.
+func (builder *InscriptionBuilder) commitPSBT() (*psbt.Packet, error) {
+	if builder.CommitTx == nil {
+		return nil, errors.New("commit tx is not built")
 	}
-
-	redeemScript, err := PayToWitnessPubKeyHashScript(btcutil.Hash160(pubKeyBytes))
+	commitPSBT, err := unsignedPSBT(builder.CommitTx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	tx.TxIn[index].SignatureScript = append([]byte{byte(len(redeemScript))}, redeemScript...)
-
-	return nil
+	for i, in := range builder.CommitTx.TxIn {
+		prevOut := builder.CommitTxPrevOutputFetcher.FetchPrevOutput(in.PreviousOutPoint)
+		commitPSBT.Inputs[i].WitnessUtxo = prevOut
+		if redeemScript := builder.CommitTxPrevOutputList[i].RedeemScript; len(redeemScript) > 0 {
+			commitPSBT.Inputs[i].WitnessScript = redeemScript
+		} else if txscript.IsPayToScriptHash(prevOut.PkScript) {
+			redeemScript, err := PayToWitnessPubKeyHashScript(btcutil.Hash160(builder.CommitTxPrivateKeyList[i].PubKey().SerializeCompressed()))
+			if err != nil {
+				return nil, err
+			}
+			commitPSBT.Inputs[i].RedeemScript = redeemScript
+		}
+	}
+	return commitPSBT, nil
 }
 
-func Sign(tx *wire.MsgTx, privateKeys []*btcec.PrivateKey, prevOutFetcher *txscript.MultiPrevOutFetcher) error {
-	txSigHashes := txscript.NewTxSigHashes(tx, prevOutFetcher)
-
-	for i, in := range tx.TxIn {
-		prevOut := prevOutFetcher.FetchPrevOutput(in.PreviousOutPoint)
-		err := SignTxInput1(privateKeys[i], tx, i, txSigHashes, prevOut.PkScript, prevOut.Value)
-		if err != nil {
-			return err
+// ParsePackagePSBT decodes the commit and reveal PSBTs of a bundle returned by PackagePSBT.
+func ParsePackagePSBT(bundle string) (*psbt.Packet, []*psbt.Packet, error) {
+	var pkg InscriptionPackagePSBT
+	if err := json.Unmarshal([]byte(bundle), &pkg); err != nil {
+		return nil, nil, err
+	}
+	commitPSBT, err := decodePSBT(pkg.CommitPSBT)
+	if err != nil {
+		return nil, nil, fmt.Errorf("commit psbt error: %w", err)
+	}
+	revealPSBTs := make([]*psbt.Packet, len(pkg.RevealPSBTs))
+	for i, revealHex := range pkg.RevealPSBTs {
+		if revealPSBTs[i], err = decodePSBT(revealHex); err != nil {
+			return nil, nil, fmt.Errorf("reveal(index %d) psbt error: %w", i, err)
 		}
 	}
+	return commitPSBT, revealPSBTs, nil
+}
 
-	return nil
+// unsignedPSBT returns a PSBT of a copy of tx without its signature scripts and witnesses.
+func unsignedPSBT(tx *wire.MsgTx) (*psbt.Packet, error) {
+	unsignedTx := tx.Copy()
+	for _, in := range unsignedTx.TxIn {
+		in.SignatureScript = nil
+		in.Witness = nil
+	}
+	return psbt.NewFromUnsignedTx(unsignedTx)
 }
 
-func GetTxHex(tx *wire.MsgTx) (string, error) {
+func encodePSBT(p *psbt.Packet) (string, error) {
 	var buf bytes.Buffer
-	if err := tx.Serialize(&buf); err != nil {
+	if err := p.Serialize(&buf); err != nil {
 		return "", err
 	}
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
-func (builder *InscriptionBuilder) GetCommitTxHex() (string, error) {
-	return GetTxHex(builder.CommitTx)
+func decodePSBT(psbtHex string) (*psbt.Packet, error) {
+	psbtBytes, err := hex.DecodeString(psbtHex)
+	if err != nil {
+		return nil, err
+	}
+	return psbt.NewFromRawBytes(bytes.NewReader(psbtBytes), false)
 }
 
-func (builder *InscriptionBuilder) GetRevealTxHexList() ([]string, error) {
-	txHexList := make([]string, len(builder.RevealTx))
-	for i := range builder.RevealTx {
-		txHex, err := GetTxHex(builder.RevealTx[i])
-		if err != nil {
-			return nil, err
+// DepositInstruction is the amount to send to a commit address to fund one inscription.
+type DepositInstruction struct {
+	Address    string `json:"address"`
+	AmountSats int64  `json:"amountSats"`
+}
+
+// DepositInstructions returns, for every inscription, its commit address and the amount funding
+// it: the postage and reveal fee carried by its commit output plus its share of the commit fee.
+// The commit fee is split evenly, the first inscriptions paying the remainder, so the amounts
+// add up to what the commit tx spends on the inscriptions. The inscriptions of an aggregated
+// reveal share one commit output and split its value the same way.
+func (builder *InscriptionBuilder) DepositInstructions() []DepositInstruction {
+	commitTxFee, _ := builder.CalculateFee()
+	total := int64(len(builder.InscriptionTxCtxDataList))
+	sharing := make(map[uint32]int64, total)
+	for _, ctx := range builder.InscriptionTxCtxDataList {
+		sharing[ctx.CommitTxOutIndex]++
+	}
+	seen := make(map[uint32]int64, total)
+	instructions := make([]DepositInstruction, total)
+	for i, ctx := range builder.InscriptionTxCtxDataList {
+		share := commitTxFee / total
+		if int64(i) < commitTxFee%total {
+			share++
+		}
+		n, k := sharing[ctx.CommitTxOutIndex], seen[ctx.CommitTxOutIndex]
+		value := ctx.RevealTxPrevOutput.Value / n
+		if k < ctx.RevealTxPrevOutput.Value%n {
+			value++
+		}
+		seen[ctx.CommitTxOutIndex]++
+		instructions[i] = DepositInstruction{
+			Address:    ctx.CommitTxAddress,
+			AmountSats: value + share,
 		}
-		txHexList[i] = txHex
 	}
-	return txHexList, nil
+	return instructions
 }
 
-func (builder *InscriptionBuilder) CalculateFee() (int64, []int64) {
-	commitTxFee := int64(0)
-	for _, in := range builder.CommitTx.TxIn {
-		commitTxFee += builder.CommitTxPrevOutputFetcher.FetchPrevOutput(in.PreviousOutPoint).Value
+// CostPerByte returns, for every inscription, its reveal fee, postage and share of the commit
+// fee, as split by DepositInstructions, divided by the length of its body in satoshis per byte.
+// An empty body counts as one byte.
+func (builder *InscriptionBuilder) CostPerByte() []float64 {
+	instructions := builder.DepositInstructions()
+	costs := make([]float64, len(instructions))
+	for i, instruction := range instructions {
+		bodySize := len(builder.InscriptionDataList[i].Body)
+		if bodySize == 0 {
+			bodySize = 1
+		}
+		costs[i] = float64(instruction.AmountSats) / float64(bodySize)
 	}
-	for _, out := range builder.CommitTx.TxOut {
-		commitTxFee -= out.Value
+	return costs
+}
+
+// WouldExceedAncestorLimit reports whether the commit tx has more reveal children than the
+// mempool accepts while it is unconfirmed. NewInscriptionTool then fails with
+// ErrAncestorLimitExceeded unless IgnoreAncestorLimit is set.
+func (builder *InscriptionBuilder) WouldExceedAncestorLimit() bool {
+	return len(builder.RevealTx)+1 > MaxPackageDescendants
+}
+
+// MinRelayablePackageRate returns the lowest uniform fee rate in sat/vB at which every tx of the
+// package pays at least the min relay fee and the package pays at least mempoolFloor, the
+// mempool minimum fee in sat/kvB as reported by getmempoolinfo.
+func (builder *InscriptionBuilder) MinRelayablePackageRate(mempoolFloor int64) int64 {
+	totalVSize := builder.TotalVSize()
+	if totalVSize == 0 {
+		return MinRelayFeeRate
 	}
-	revealTxFees := make([]int64, 0)
-	for _, tx := range builder.RevealTx {
-		revealTxFee := int64(0)
-		for i, in := range tx.TxIn {
-			revealTxFee += builder.RevealTxPrevOutputFetcher.FetchPrevOutput(in.PreviousOutPoint).Value
-			revealTxFee -= tx.TxOut[i].Value
-			revealTxFees = append(revealTxFees, revealTxFee)
-		}
+	minPackageFee := (mempoolFloor*totalVSize + 999) / 1000
+	rate := (minPackageFee + totalVSize - 1) / totalVSize
+	if rate < MinRelayFeeRate {
+		rate = MinRelayFeeRate
 	}
-	return commitTxFee, revealTxFees
+	return rate
 }
 
 func Inscribe(network *chaincfg.Params, request *InscriptionRequest) (*InscribeTxs, error) {
-	tool, err := NewInscriptionTool(network, request)
-	if err != nil && err.Error() == "insufficient balance" {
+	return InscribeContext(context.Background(), network, request)
+}
+
+// InscribeContext is Inscribe stopping with the error of ctx, such as context.Canceled, once ctx
+// is done. ctx is checked before building each inscription and before signing each reveal input.
+func InscribeContext(ctx context.Context, network *chaincfg.Params, request *InscriptionRequest) (*InscribeTxs, error) {
+	tool, err := newInscriptionTool(ctx, network, request)
+	if errors.Is(err, ErrInsufficientBalance) {
 		return &InscribeTxs{
 			CommitTx:     "",
 			RevealTxs:    []string{},
@@ -488,15 +3565,134 @@ func Inscribe(network *chaincfg.Params, request *InscriptionRequest) (*InscribeT
 
 	commitTxFee, revealTxFees := tool.CalculateFee()
 
+	revealTxIds := make([]string, len(tool.RevealTx))
+	for i, tx := range tool.RevealTx {
+		revealTxIds[i] = tx.TxHash().String()
+	}
+
 	return &InscribeTxs{
-		CommitTx:     commitTx,
-		RevealTxs:    revealTxs,
-		CommitTxFee:  commitTxFee,
-		RevealTxFees: revealTxFees,
-		CommitAddrs:  tool.CommitAddrs,
+		CommitTx:        commitTx,
+		RevealTxs:       revealTxs,
+		CommitTxFee:     commitTxFee,
+		RevealTxFees:    revealTxFees,
+		CommitAddrs:     tool.CommitAddrs,
+		CommitTxId:      tool.CommitTx.TxHash().String(),
+		RevealTxIds:     revealTxIds,
+		TotalInputValue: tool.TotalInputValue(),
+		TotalPostage:    tool.TotalPostage(),
+		ChangeValue:     tool.ChangeValue(),
 	}, nil
 }
 
+// InscribeAirdrop inscribes data once for every recipient, all the reveal txs being funded by
+// a single commit tx spending utxos. More than 24 recipients exceed the mempool descendant
+// limit of the commit tx and fail with ErrAncestorLimitExceeded.
+func InscribeAirdrop(network *chaincfg.Params, utxos []*PrevOutput, data InscriptionData, recipients []string, postage, commitRate, revealRate int64, changeAddr string) (*InscribeTxs, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no airdrop recipients")
+	}
+	inscriptionDataList := make([]InscriptionData, len(recipients))
+	for i, recipient := range recipients {
+		inscriptionDataList[i] = InscriptionData{
+			ContentType:     data.ContentType,
+			Body:            data.Body,
+			RevealAddr:      recipient,
+			ContentEncoding: data.ContentEncoding,
+			CompressBody:    data.CompressBody,
+		}
+	}
+	return Inscribe(network, &InscriptionRequest{
+		CommitTxPrevOutputList: utxos,
+		CommitFeeRate:          commitRate,
+		RevealFeeRate:          revealRate,
+		InscriptionDataList:    inscriptionDataList,
+		RevealOutValue:         postage,
+		ChangeAddress:          changeAddr,
+	})
+}
+
+// Broadcaster sends a signed raw transaction to the network and returns its txid.
+// The sdk does not ship an implementation, callers plug in their own node or api client.
+type Broadcaster interface {
+	Broadcast(txHex string) (string, error)
+}
+
+// InscribeAndBroadcast builds the inscription txs and broadcasts the commit tx followed by
+// the reveal txs in order. It does not wait for the commit tx to confirm, the reveal txs
+// are valid to relay as soon as the commit tx is in the mempool.
+func InscribeAndBroadcast(network *chaincfg.Params, request *InscriptionRequest, broadcaster Broadcaster) (*InscribeTxs, error) {
+	txs, err := Inscribe(network, request)
+	if err != nil {
+		return nil, err
+	}
+	if txs.CommitTx == "" {
+		return txs, ErrInsufficientBalance
+	}
+	if _, err := broadcaster.Broadcast(txs.CommitTx); err != nil {
+		return txs, fmt.Errorf("broadcast commit tx error: %w", err)
+	}
+	for i, revealTx := range txs.RevealTxs {
+		if _, err := broadcaster.Broadcast(revealTx); err != nil {
+			return txs, fmt.Errorf("broadcast reveal(index %d) tx error: %w", i, err)
+		}
+	}
+	return txs, nil
+}
+
+// BuildCPFP returns the signed hex of a child tx spending output changeVout of the parent tx to
+// destAddr, so that the package of both txs pays newFeeRate sat/vB on their combined size. The
+// fee already paid by the parent can not be known from its hex alone, so the child pays the
+// whole package at newFeeRate and the effective package rate is at least newFeeRate.
+func BuildCPFP(parentTxHex string, changeVout uint32, changePrivKey string, destAddr string, newFeeRate int64, network *chaincfg.Params) (string, error) {
+	parentTx, err := NewTxFromHex(parentTxHex)
+	if err != nil {
+		return "", err
+	}
+	if int(changeVout) >= len(parentTx.TxOut) {
+		return "", fmt.Errorf("change vout %d is out of range of the %d parent outputs", changeVout, len(parentTx.TxOut))
+	}
+	if newFeeRate < MinRelayFeeRate || newFeeRate > MaxFeeRate {
+		return "", fmt.Errorf("%w: cpfp fee rate %d sat/vB is not between %d and %d", ErrFeeRateOutOfRange, newFeeRate, MinRelayFeeRate, MaxFeeRate)
+	}
+	privateKeyWif, err := btcutil.DecodeWIF(changePrivKey)
+	if err != nil {
+		return "", err
+	}
+	if !privateKeyWif.IsForNet(network) {
+		return "", fmt.Errorf("change private key is not for network %s", network.Name)
+	}
+	destPkScript, err := AddrToPkScript(destAddr, network)
+	if err != nil {
+		return "", err
+	}
+
+	change := parentTx.TxOut[changeVout]
+	parentTxHash := parentTx.TxHash()
+	outPoint := wire.NewOutPoint(&parentTxHash, changeVout)
+	prevOutFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	prevOutFetcher.AddPrevOut(*outPoint, change)
+	tx := wire.NewMsgTx(DefaultTxVersion)
+	in := wire.NewTxIn(outPoint, nil, nil)
+	in.Sequence = DefaultSequenceNum
+	tx.AddTxIn(in)
+	tx.AddTxOut(wire.NewTxOut(change.Value, destPkScript))
+	privateKeys := []*btcec.PrivateKey{privateKeyWif.PrivKey}
+	if err := Sign(tx, privateKeys, prevOutFetcher); err != nil {
+		return "", err
+	}
+
+	fee := computeFee(GetTransactionWeight2(parentTx)+GetTransactionWeight2(tx), newFeeRate, RoundUp)
+	value := change.Value - fee
+	if dust := GetDustThreshold(destPkScript); value < dust {
+		return "", fmt.Errorf("%w: change value %d can not pay the package fee %d and the dust threshold %d of %s", ErrInsufficientBalance, change.Value, fee, dust, destAddr)
+	}
+	tx.TxOut[0].Value = value
+	if err := Sign(tx, privateKeys, prevOutFetcher); err != nil {
+		return "", err
+	}
+	return GetTxHex(tx)
+}
+
 // GetTransactionWeight computes the value of the weight metric for a given
 // transaction. Currently the weight metric is simply the sum of the
 // transactions's serialized size without any witness data scaled
@@ -532,35 +3728,78 @@ func GetTxVirtualSize(tx *btcutil.Tx) int64 {
 	return (GetTransactionWeight(tx) + (WitnessScaleFactor - 1)) / WitnessScaleFactor
 }
 func InscribeForMPCUnsigned(request *InscriptionRequest, network *chaincfg.Params, unsignedCommitHash, signedCommitTxHash *chainhash.Hash) (*InscribeForMPCRes, error) {
+	request = inscribedInputsFirst(request)
+	preservedOutputs := inscribedInputCount(request.CommitTxPrevOutputList)
+	if request.SingleRevealTx {
+		return nil, errors.New("the mpc flow does not support a single reveal tx")
+	}
+	if request.AggregateReveal {
+		return nil, errors.New("the mpc flow does not support an aggregated reveal")
+	}
+	if request.SelectInputs {
+		return nil, errors.New("the mpc flow does not support input selection")
+	}
+	if request.CommitSigHashType != 0 {
+		return nil, errors.New("the mpc flow does not support a commit sighash type")
+	}
+	if len(request.CommitOpReturn) > 0 {
+		return nil, errors.New("the mpc flow does not support a commit op return output")
+	}
+	if len(request.ChangeOutputs) > 0 {
+		return nil, errors.New("the mpc flow does not support split change outputs")
+	}
+	for i, prevOutput := range request.CommitTxPrevOutputList {
+		if len(prevOutput.TapMerkleRoot) > 0 {
+			return nil, fmt.Errorf("commit input %d has a tap merkle root, which the mpc flow does not support", i)
+		}
+		if len(prevOutput.RedeemScript) > 0 {
+			return nil, fmt.Errorf("commit input %d is a multisig input, which the mpc flow does not support", i)
+		}
+		if prevOutput.PreserveFirstSat {
+			return nil, fmt.Errorf("commit input %d preserves its first sat, which the mpc flow does not support", i)
+		}
+	}
 
-	wif, err := btcutil.DecodeWIF(request.CommitTxPrevOutputList[0].PrivateKey)
+	scriptCtxList, err := buildInscriptionScriptCtxList(request, network)
 	if err != nil {
 		return nil, err
 	}
-	randPrvKey := wif.PrivKey
-	scriptCtxList, err := buildInscriptionScriptCtxList(request, network)
+	for i, ctx := range scriptCtxList {
+		if ctx.ParentPrevOutput != nil {
+			return nil, fmt.Errorf("inscription(index %d) has a parent, which the mpc flow does not support", i)
+		}
+		if len(ctx.Annex) > 0 {
+			return nil, fmt.Errorf("inscription(index %d) has a reveal annex, which the mpc flow does not support", i)
+		}
+		if len(request.InscriptionDataList[i].ExtraRevealOutputs) > 0 {
+			return nil, fmt.Errorf("inscription(index %d) has extra reveal outputs, which the mpc flow does not support", i)
+		}
+	}
+	commitFeeRate, revealFeeRates, err := normalizeFeeRates(network, request)
 	if err != nil {
 		return nil, err
 	}
 
+	postages := revealOutValues(request)
+
 	// build reveal tx list
 	revealTxList := make([]*wire.MsgTx, len(scriptCtxList))
-	commitTxOutList := make([]*wire.TxOut, 0)
+	commitTxOutList := make([]*wire.TxOut, len(scriptCtxList))
 	totalRevealInValue := int64(0)
 	for i, ctx := range scriptCtxList {
 		revealTx := wire.NewMsgTx(DefaultTxVersion)
 
 		in := wire.NewTxIn(&wire.OutPoint{Index: uint32(i)}, nil, nil)
-		in.Sequence = DefaultSequenceNum
+		in.Sequence = inputSequence(request.DisableRBF)
 		revealTx.AddTxIn(in)
 
-		scriptPubKey, err := AddrToPkScript(request.InscriptionDataList[i].RevealAddr, network)
+		scriptPubKey, err := revealPkScript(network, i, request.InscriptionDataList[i])
 		if err != nil {
 			return nil, err
 		}
-		revealOutValue := DefaultRevealOutValue
-		if request.RevealOutValue > 0 {
-			revealOutValue = request.RevealOutValue
+		revealOutValue := postages[i]
+		if dust := GetDustThreshold(scriptPubKey); revealOutValue < dust && !request.AllowDust {
+			return nil, fmt.Errorf("reveal(index %d) output value %d is below the dust threshold %d of %s", i, revealOutValue, dust, request.InscriptionDataList[i].RevealAddr)
 		}
 		out := wire.NewTxOut(revealOutValue, scriptPubKey)
 		revealTx.AddTxOut(out)
@@ -569,9 +3808,16 @@ func InscribeForMPCUnsigned(request *InscriptionRequest, network *chaincfg.Param
 
 		emptySignature := make([]byte, 64)
 		emptyControlBlockWitness := make([]byte, 33)
-		fakeWitness := wire.TxWitness{emptySignature, ctx.InscriptionScript, emptyControlBlockWitness}
-		revealFee := int64(revealTx.SerializeSize()+((fakeWitness.SerializeSize()+2+3)/4)) * request.RevealFeeRate
+		feeBufferPercent := request.FeeBufferPercent
+		if request.ExactRevealPostage {
+			feeBufferPercent = 0
+		}
+		fakeWitness := ctx.revealWitness(emptySignature, emptyControlBlockWitness)
+		revealFee := applyFeeBuffer(computeFee(revealTxWeight(revealTx, fakeWitness), revealFeeRates[i], request.FeeRoundingMode), feeBufferPercent)
 		revealInValue := revealOutValue + revealFee
+		if err := checkRevealPrevOutputValue(i, revealInValue, revealFee, scriptPubKey, request.AllowDust); err != nil {
+			return nil, err
+		}
 
 		ctx.RevealTxPrevOutput = &wire.TxOut{
 			PkScript: ctx.CommitTxAddressPkScript,
@@ -579,7 +3825,7 @@ func InscribeForMPCUnsigned(request *InscriptionRequest, network *chaincfg.Param
 		}
 		totalRevealInValue += revealInValue
 
-		commitTxOutList = append(commitTxOutList, wire.NewTxOut(revealInValue, ctx.CommitTxAddressPkScript))
+		commitTxOutList[ctx.CommitTxOutIndex-preservedOutputs] = wire.NewTxOut(revealInValue, ctx.CommitTxAddressPkScript)
 	}
 
 	// build commit tx
@@ -594,7 +3840,7 @@ func InscribeForMPCUnsigned(request *InscriptionRequest, network *chaincfg.Param
 		outPoint := wire.NewOutPoint(txHash, utxo.VOut)
 
 		in := wire.NewTxIn(outPoint, nil, nil)
-		in.Sequence = DefaultSequenceNum
+		in.Sequence = inputSequence(request.DisableRBF)
 		commitTx.AddTxIn(in)
 
 		pkScript, err := AddrToPkScript(utxo.Address, network)
@@ -604,6 +3850,10 @@ func InscribeForMPCUnsigned(request *InscriptionRequest, network *chaincfg.Param
 		txOut := wire.NewTxOut(utxo.Amount, pkScript)
 		prevOutFetcher.AddPrevOut(*outPoint, txOut)
 
+		if utxo.HasInscription {
+			commitTx.AddTxOut(wire.NewTxOut(utxo.Amount, pkScript))
+			continue
+		}
 		totalCommitInValue += utxo.Amount
 	}
 
@@ -611,7 +3861,7 @@ func InscribeForMPCUnsigned(request *InscriptionRequest, network *chaincfg.Param
 		commitTx.AddTxOut(commitTxOut)
 	}
 
-	changePkScript, err := AddrToPkScript(request.ChangeAddress, network)
+	changePkScript, err := requestChangePkScript(network, request)
 	if err != nil {
 		return nil, err
 	}
@@ -630,20 +3880,23 @@ func InscribeForMPCUnsigned(request *InscriptionRequest, network *chaincfg.Param
 		return nil, err
 	}
 
-	commitFee := GetTxVirtualSize(btcutil.NewTx(estimateTx)) * request.CommitFeeRate
+	commitFee := applyFeeBuffer(computeFee(GetTransactionWeight(btcutil.NewTx(estimateTx)), commitFeeRate, request.FeeRoundingMode), request.FeeBufferPercent)
 	changeValue := totalCommitInValue - totalRevealInValue - commitFee
-	minChangeValue := DefaultMinChangeValue
+	_, minChangeValue := NetworkDefaults(network)
 	if request.MinChangeValue > 0 {
 		minChangeValue = request.MinChangeValue
 	}
+	if !sacrificeExcessToFee(request) {
+		minChangeValue = GetDustThreshold(changePkScript)
+	}
 	if changeValue >= minChangeValue {
 		commitTx.TxOut[len(commitTx.TxOut)-1].Value = changeValue
 	} else {
 		commitTx.TxOut = commitTx.TxOut[:len(commitTx.TxOut)-1]
 		estimateTx.TxOut = estimateTx.TxOut[:len(estimateTx.TxOut)-1]
-		feeWithoutChange := GetTxVirtualSize(btcutil.NewTx(estimateTx)) * request.CommitFeeRate
+		feeWithoutChange := applyFeeBuffer(computeFee(GetTransactionWeight(btcutil.NewTx(estimateTx)), commitFeeRate, request.FeeRoundingMode), request.FeeBufferPercent)
 		if totalCommitInValue-totalRevealInValue-feeWithoutChange < 0 {
-			return nil, errors.New("insufficient balance")
+			return nil, ErrInsufficientBalance
 		}
 	}
 
@@ -660,18 +3913,19 @@ func InscribeForMPCUnsigned(request *InscriptionRequest, network *chaincfg.Param
 	commitAddrs := make([]string, len(scriptCtxList))
 	for i, ctx := range scriptCtxList {
 		revealTxList[i].TxIn[0].PreviousOutPoint.Hash = commitTxHash
-		outPoint := wire.NewOutPoint(&commitTxHash, uint32(i))
+		revealTxList[i].TxIn[0].PreviousOutPoint.Index = ctx.CommitTxOutIndex
+		outPoint := wire.NewOutPoint(&commitTxHash, ctx.CommitTxOutIndex)
 		revealTxPrevOutFetcher := txscript.NewMultiPrevOutFetcher(nil)
 		revealTxPrevOutFetcher.AddPrevOut(*outPoint, ctx.RevealTxPrevOutput)
 		txSigHashes := txscript.NewTxSigHashes(revealTxList[i], revealTxPrevOutFetcher)
 		tapLeaf := txscript.NewBaseTapLeaf(ctx.InscriptionScript)
 
 		signature, err := txscript.RawTxInTapscriptSignature(revealTxList[i], txSigHashes, 0,
-			ctx.RevealTxPrevOutput.Value, ctx.RevealTxPrevOutput.PkScript, tapLeaf, txscript.SigHashDefault, randPrvKey)
+			ctx.RevealTxPrevOutput.Value, ctx.RevealTxPrevOutput.PkScript, tapLeaf, txscript.SigHashDefault, ctx.PrivateKey)
 		if err != nil {
 			return nil, err
 		}
-		revealTxList[i].TxIn[0].Witness = wire.TxWitness{signature, ctx.InscriptionScript, ctx.ControlBlockWitness}
+		revealTxList[i].TxIn[0].Witness = ctx.revealWitness(signature, ctx.ControlBlockWitness)
 
 		revealTxFee := int64(0)
 		tx := revealTxList[i]
@@ -695,12 +3949,14 @@ func InscribeForMPCUnsigned(request *InscriptionRequest, network *chaincfg.Param
 		return nil, err
 	}
 	revealTxHexList := make([]string, 0)
+	revealTxIds := make([]string, 0, len(revealTxList))
 	for _, tx := range revealTxList {
 		s, err := GetTxHex(tx)
 		if err != nil {
 			return nil, err
 		}
 		revealTxHexList = append(revealTxHexList, s)
+		revealTxIds = append(revealTxIds, tx.TxHash().String())
 	}
 	res := &InscribeForMPCRes{
 		SigHashList:  sigHashList,
@@ -709,6 +3965,8 @@ func InscribeForMPCUnsigned(request *InscriptionRequest, network *chaincfg.Param
 		CommitTxFee:  commitTxFee,
 		RevealTxFees: revealTxFees,
 		CommitAddrs:  commitAddrs,
+		CommitTxId:   commitTxHash.String(),
+		RevealTxIds:  revealTxIds,
 	}
 	return res, nil
 }
@@ -763,11 +4021,166 @@ func InscribeForMPCSigned(request *InscriptionRequest, network *chaincfg.Params,
 	if err != nil {
 		return nil, err
 	}
+	// the reveal txs are rebuilt from the request and spend the signed commit tx by its post-signing txid
+	// (legacy inputs change the txid when the signature script is filled in), so make sure the outputs
+	// they spend are exactly the ones in the commit tx that was signed.
+	rebuiltCommitTx, err := NewTxFromHex(res.CommitTx)
+	if err != nil {
+		return nil, err
+	}
+	committedOutputs := int(inscribedInputCount(request.CommitTxPrevOutputList)) + len(res.RevealTxs)
+	if len(tx.TxOut) < committedOutputs {
+		return nil, errors.New("signed commit tx does not match the request")
+	}
+	for i := 0; i < committedOutputs; i++ {
+		if tx.TxOut[i].Value != rebuiltCommitTx.TxOut[i].Value || !bytes.Equal(tx.TxOut[i].PkScript, rebuiltCommitTx.TxOut[i].PkScript) {
+			return nil, fmt.Errorf("signed commit tx output %d does not match the request", i)
+		}
+	}
+	commitTxFee := int64(0)
+	for _, prevOutput := range request.CommitTxPrevOutputList {
+		commitTxFee += prevOutput.Amount
+	}
+	for _, out := range tx.TxOut {
+		commitTxFee -= out.Value
+	}
 	res.SigHashList = nil
 	res.CommitTx = signedCommitTxHex
+	res.CommitTxFee = commitTxFee
 	return res, nil
 }
 
+// assignCommitFundingVouts sets the commit output index of every inscription, the first
+// offset commit outputs being the ones preserving inscribed inputs.
+func assignCommitFundingVouts(ctxList []*inscriptionTxCtxData, dataList []InscriptionData, offset uint32) error {
+	positional := true
+	for _, data := range dataList {
+		if data.CommitFundingVout != 0 {
+			positional = false
+			break
+		}
+	}
+	used := make([]bool, len(ctxList))
+	for i, ctx := range ctxList {
+		vout := i
+		if !positional {
+			vout = dataList[i].CommitFundingVout
+		}
+		if vout < 0 || vout >= len(ctxList) || used[vout] {
+			return fmt.Errorf("inscription(index %d) commit funding vout %d is out of range or already used", i, vout)
+		}
+		used[vout] = true
+		ctx.CommitTxOutIndex = offset + uint32(vout)
+	}
+	return nil
+}
+
+// inscribedInputsFirst returns a copy of request whose commit inputs carrying an inscription
+// come first, so that the inscribed sats flow into the first commit outputs preserving them,
+// followed by the input whose first sat is preserved.
+func inscribedInputsFirst(request *InscriptionRequest) *InscriptionRequest {
+	ordered := *request
+	ordered.CommitTxPrevOutputList = make([]*PrevOutput, 0, len(request.CommitTxPrevOutputList))
+	for _, prevOutput := range request.CommitTxPrevOutputList {
+		if prevOutput.HasInscription {
+			ordered.CommitTxPrevOutputList = append(ordered.CommitTxPrevOutputList, prevOutput)
+		}
+	}
+	for _, prevOutput := range request.CommitTxPrevOutputList {
+		if !prevOutput.HasInscription && prevOutput.PreserveFirstSat {
+			ordered.CommitTxPrevOutputList = append(ordered.CommitTxPrevOutputList, prevOutput)
+		}
+	}
+	for _, prevOutput := range request.CommitTxPrevOutputList {
+		if !prevOutput.HasInscription && !prevOutput.PreserveFirstSat {
+			ordered.CommitTxPrevOutputList = append(ordered.CommitTxPrevOutputList, prevOutput)
+		}
+	}
+	return &ordered
+}
+
+// checkPreserveFirstSat checks that the first sat of the commit input with PreserveFirstSat,
+// if any, lands on the first sat of the reveal output funded by the first commit output: the
+// input must come first, no input selection may reorder it and nothing may be spent ahead of
+// it in the reveal tx. The postage of that inscription must be the value of the input.
+func checkPreserveFirstSat(request *InscriptionRequest) error {
+	index := -1
+	for i, prevOutput := range request.CommitTxPrevOutputList {
+		if !prevOutput.PreserveFirstSat {
+			continue
+		}
+		if index >= 0 {
+			return fmt.Errorf("commit inputs %d and %d both preserve their first sat", index, i)
+		}
+		index = i
+	}
+	if index < 0 {
+		return nil
+	}
+	if inscribedInputCount(request.CommitTxPrevOutputList) > 0 {
+		return fmt.Errorf("commit input %d can not preserve its first sat behind inputs carrying an inscription", index)
+	}
+	if request.SelectInputs {
+		return fmt.Errorf("commit input %d can not preserve its first sat with input selection", index)
+	}
+	if len(request.InscriptionDataList) == 0 {
+		return nil
+	}
+	// the first commit output funds the first inscription, or the one with commit funding vout 0
+	// when the vouts are explicit
+	inscription := 0
+	for _, data := range request.InscriptionDataList {
+		if data.CommitFundingVout == 0 {
+			continue
+		}
+		for i, other := range request.InscriptionDataList {
+			if other.CommitFundingVout == 0 {
+				inscription = i
+				break
+			}
+		}
+		break
+	}
+	if request.InscriptionDataList[inscription].ParentPrevOutput != nil {
+		return fmt.Errorf("inscription(index %d) with a parent can not be made on the first sat of commit input %d", inscription, index)
+	}
+	amount := request.CommitTxPrevOutputList[index].Amount
+	if postage := revealOutValues(request)[inscription]; postage != amount {
+		return fmt.Errorf("inscription(index %d) postage %d must equal the value %d of commit input %d preserving its first sat", inscription, postage, amount, index)
+	}
+	return nil
+}
+
+func inscribedInputCount(prevOutputList []*PrevOutput) uint32 {
+	count := uint32(0)
+	for _, prevOutput := range prevOutputList {
+		if prevOutput.HasInscription {
+			count++
+		}
+	}
+	return count
+}
+
+// requestChangePkScript returns the ChangePkScript of request if set, or else the script of
+// its ChangeAddress.
+func requestChangePkScript(network *chaincfg.Params, request *InscriptionRequest) ([]byte, error) {
+	if len(request.ChangePkScript) == 0 {
+		pkScript, err := AddrToPkScript(request.ChangeAddress, network)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %v", ErrInvalidChangeAddress, request.ChangeAddress, err)
+		}
+		return pkScript, nil
+	}
+	if txscript.GetScriptClass(request.ChangePkScript) == txscript.NonStandardTy {
+		return nil, fmt.Errorf("%w: change pk script is not a standard script", ErrInvalidChangeAddress)
+	}
+	return request.ChangePkScript, nil
+}
+
+func sacrificeExcessToFee(request *InscriptionRequest) bool {
+	return request.SacrificeExcessToFee == nil || *request.SacrificeExcessToFee
+}
+
 func buildInscriptionScriptCtxList(request *InscriptionRequest, network *chaincfg.Params) ([]*inscriptionTxCtxData, error) {
 	var scriptCtxList []*inscriptionTxCtxData
 	for i := range request.InscriptionDataList {
@@ -778,6 +4191,14 @@ func buildInscriptionScriptCtxList(request *InscriptionRequest, network *chaincf
 
 		scriptCtxList = append(scriptCtxList, scriptCtx)
 	}
+	if err := assignCommitFundingVouts(scriptCtxList, request.InscriptionDataList, inscribedInputCount(request.CommitTxPrevOutputList)); err != nil {
+		return nil, err
+	}
+	if request.AggregateReveal {
+		if err := aggregateRevealCtxList(network, request, scriptCtxList); err != nil {
+			return nil, err
+		}
+	}
 
 	return scriptCtxList, nil
 }
@@ -1,9 +1,27 @@
 package bitcoin
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/stretchr/testify/require"
+	"math"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -79,7 +97,7 @@ func TestInscribe(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	expected := `{"commitTx":"02000000000104b5215a023a50176369969d886fb32a40c0b883862ab750cd061ff339dda63a4500000000171600145c005c5532ce810ddf20f9d1d939631b47089ecdfdffffffd40825b8dca2dda833e9f653da0c2930611078099c959459eea92a9f86a4c8220000000000fdffffff8789f89f3e2e4e5015765b1b1382ad3aa634d2092785bcd5965699c25e206f3c000000006b483045022100f754ad06bad6452f96ca89fcde5f8fb5d66f5add8ea95c0d3c28ef5209a7a58d022045259c123ed509acdf625fa41c449776f4e0a049bc9ab1f0f2c136ef9896a9ce01210357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2ffdffffff26bd8a346a51065b121a33830fbe2c7f2d3f8ddbc34318deb7e2a0dd48fa09aa0400000000fdffffff0550030000000000002251206ff0ac47ccff79fc3eaab0cd0047c28dead95cd35c6c695dfe33010b8807d16c3c03000000000000225120845a93ad3f2f36750672201709a48e6ad458cc0a42455f0786cf3bbbe42a6d183803000000000000225120be60aa4826e2e3a3245158c0e7b36543ed7ead2ed40a541c4583b80d4b3762003803000000000000225120e7ff49e9dee3ddaf3a811f12954a9c66cc98bf01c4eccb1ec093acf04ee2d1ff8062110000000000225120b7ee7f83a6a7fdb513040856c56778aa3abea9a451e0c9bb012f22a77ed99b210247304402207589b3e41b82547801a3613efbd3edb1438576679f211ee104e30e02732e42a702200341c77095a196fb7e4c20eb446fb7e9ab6ab4d02609eb72a26708cf7b453daa01210357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f02483045022100f56201b18bd33472e19f4564a84c819b08af6fddab55ca408112f12cabc849be0220343f5c7f391b5cb69ef90a41d513bf1b05f24f4c6701ad4fb2db3e9d1a64ab4c01210357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f000140dca26614fd80c47dee4eb2db0c776c2e888c453d51afc2fc01d28b1e2903d1f444e0717d6c1d4110d3631e1c480a1b20314315c72929945606acdcb01309910b00000000","revealTxs":["02000000000101a4a801d4e06cf7d6e3d376686edb048e26cede46bf248f94ddb290dfe9d426640000000000fdffffff012202000000000000225120b7ee7f83a6a7fdb513040856c56778aa3abea9a451e0c9bb012f22a77ed99b21034061d734a5a91aacb5a257a74e73ed6ed99d81918e3be4f917cb1532b7087175d807cb6f7a7e6518ed1db087318e9ed536071d4fe3e86590abd01bb1335484cccf7a2057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800347b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a22313030227d6821c057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000","02000000000101a4a801d4e06cf7d6e3d376686edb048e26cede46bf248f94ddb290dfe9d426640100000000fdffffff0122020000000000001976a9145c005c5532ce810ddf20f9d1d939631b47089ecd88ac03403876a2ef916ebc497912941b6bee621389a734a8d88eb639bde717bb614f0aa42e511745506a70ee5f4693cfbd17df015e49cbd53fbbec37cf15f3b9b5cd7dfe792057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800337b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a223130227d6821c157bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000","02000000000101a4a801d4e06cf7d6e3d376686edb048e26cede46bf248f94ddb290dfe9d426640200000000fdffffff0122020000000000001600145c005c5532ce810ddf20f9d1d939631b47089ecd0340f3af92405a2fbb5105cad1a9c498432ff9e69097801b3997d149d962fca8a84f68c4c3a7e46723c4f503c46b62aac751ade35a3de8882247d329c01e98863ff87c2057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800367b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a223130303030227d6821c057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000","02000000000101a4a801d4e06cf7d6e3d376686edb048e26cede46bf248f94ddb290dfe9d426640300000000fdffffff01220200000000000017a914ef05515a0595d15eaf90d9f62fb85873a6d8c0b487034097ee19b8f9a51bc32cd8cacb16e8abf8a12119a58d0c591f1072286034e4ff7622b271d8a1d7b87ba1b958bfe7eaa23d2923a8b32793e23f3be594a565b8e3cf782057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800327b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a2231227d6821c057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000"],"commitTxFee":1182,"revealTxFees":[302,282,278,278],"commitAddrs":["tb1pdlc2c37vlaulc042krxsq37z3h4djhxnt3kxjh07xvqshzq869kqz5sgrc","tb1ps3df8tfl9um82pnjyqtsnfywdt293nq2gfz47puxeuamhep2d5vq0jujz6","tb1phes25jpxut36xfz3trqw0vm9g0khatfw6s99g8z9swuq6jehvgqqdsrvg2","tb1pull5n6w7u0w67w5pruff2j5uvmxf30cpcnkvk8kqjwk0qnhz68ls68tklf"]}`
+	expected := `{"commitTx":"02000000000104b5215a023a50176369969d886fb32a40c0b883862ab750cd061ff339dda63a4500000000171600145c005c5532ce810ddf20f9d1d939631b47089ecdfdffffffd40825b8dca2dda833e9f653da0c2930611078099c959459eea92a9f86a4c8220000000000fdffffff8789f89f3e2e4e5015765b1b1382ad3aa634d2092785bcd5965699c25e206f3c000000006b483045022100f754ad06bad6452f96ca89fcde5f8fb5d66f5add8ea95c0d3c28ef5209a7a58d022045259c123ed509acdf625fa41c449776f4e0a049bc9ab1f0f2c136ef9896a9ce01210357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2ffdffffff26bd8a346a51065b121a33830fbe2c7f2d3f8ddbc34318deb7e2a0dd48fa09aa0400000000fdffffff0550030000000000002251206ff0ac47ccff79fc3eaab0cd0047c28dead95cd35c6c695dfe33010b8807d16c3c03000000000000225120845a93ad3f2f36750672201709a48e6ad458cc0a42455f0786cf3bbbe42a6d183803000000000000225120be60aa4826e2e3a3245158c0e7b36543ed7ead2ed40a541c4583b80d4b3762003803000000000000225120e7ff49e9dee3ddaf3a811f12954a9c66cc98bf01c4eccb1ec093acf04ee2d1ff8062110000000000225120b7ee7f83a6a7fdb513040856c56778aa3abea9a451e0c9bb012f22a77ed99b210247304402207589b3e41b82547801a3613efbd3edb1438576679f211ee104e30e02732e42a702200341c77095a196fb7e4c20eb446fb7e9ab6ab4d02609eb72a26708cf7b453daa01210357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f02483045022100f56201b18bd33472e19f4564a84c819b08af6fddab55ca408112f12cabc849be0220343f5c7f391b5cb69ef90a41d513bf1b05f24f4c6701ad4fb2db3e9d1a64ab4c01210357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f000140dca26614fd80c47dee4eb2db0c776c2e888c453d51afc2fc01d28b1e2903d1f444e0717d6c1d4110d3631e1c480a1b20314315c72929945606acdcb01309910b00000000","revealTxs":["02000000000101a4a801d4e06cf7d6e3d376686edb048e26cede46bf248f94ddb290dfe9d426640000000000fdffffff012202000000000000225120b7ee7f83a6a7fdb513040856c56778aa3abea9a451e0c9bb012f22a77ed99b21034061d734a5a91aacb5a257a74e73ed6ed99d81918e3be4f917cb1532b7087175d807cb6f7a7e6518ed1db087318e9ed536071d4fe3e86590abd01bb1335484cccf7a2057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800347b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a22313030227d6821c057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000","02000000000101a4a801d4e06cf7d6e3d376686edb048e26cede46bf248f94ddb290dfe9d426640100000000fdffffff0122020000000000001976a9145c005c5532ce810ddf20f9d1d939631b47089ecd88ac03403876a2ef916ebc497912941b6bee621389a734a8d88eb639bde717bb614f0aa42e511745506a70ee5f4693cfbd17df015e49cbd53fbbec37cf15f3b9b5cd7dfe792057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800337b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a223130227d6821c157bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000","02000000000101a4a801d4e06cf7d6e3d376686edb048e26cede46bf248f94ddb290dfe9d426640200000000fdffffff0122020000000000001600145c005c5532ce810ddf20f9d1d939631b47089ecd0340f3af92405a2fbb5105cad1a9c498432ff9e69097801b3997d149d962fca8a84f68c4c3a7e46723c4f503c46b62aac751ade35a3de8882247d329c01e98863ff87c2057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800367b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a223130303030227d6821c057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000","02000000000101a4a801d4e06cf7d6e3d376686edb048e26cede46bf248f94ddb290dfe9d426640300000000fdffffff01220200000000000017a914ef05515a0595d15eaf90d9f62fb85873a6d8c0b487034097ee19b8f9a51bc32cd8cacb16e8abf8a12119a58d0c591f1072286034e4ff7622b271d8a1d7b87ba1b958bfe7eaa23d2923a8b32793e23f3be594a565b8e3cf782057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800327b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a2231227d6821c057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000"],"commitTxFee":1182,"revealTxFees":[302,282,278,278],"commitAddrs":["tb1pdlc2c37vlaulc042krxsq37z3h4djhxnt3kxjh07xvqshzq869kqz5sgrc","tb1ps3df8tfl9um82pnjyqtsnfywdt293nq2gfz47puxeuamhep2d5vq0jujz6","tb1phes25jpxut36xfz3trqw0vm9g0khatfw6s99g8z9swuq6jehvgqqdsrvg2","tb1pull5n6w7u0w67w5pruff2j5uvmxf30cpcnkvk8kqjwk0qnhz68ls68tklf"],"commitTxId":"6426d4e9df90b2dd948f24bf46dece268e04db6e6876d3e3d6f76ce0d401a8a4","revealTxIds":["84b3e3ac135ac16515d92c023689b2d397ed6b92fd2b7402bd38e8f38bdfc61a","749cbeeea58d07340201eb868fce024d502a9bb6ec10755964c947edfed9f26b","56ec6f63f4e06571e44d66772e9bb10cdb05d29ac352120383260295a6e68c73","2dae115a35eb6f8286383d0322045382f48b8de4ee1cbb24b45fe559f18ff711"],"totalInputValue":1143834,"totalPostage":2184,"changeValue":1139328}`
 	require.Equal(t, expected, string(txsBytes))
 }
 
@@ -154,7 +172,2858 @@ func TestInscribeForMPCUnsigned(t *testing.T) {
 	require.NoError(t, err)
 	rb, err := json.Marshal(res)
 	require.NoError(t, err)
-	expected := `{"sigHashList":["89d176c6dd56cf7ac84c2b0136098c7394cdcb29318c8513092150af7f0ef685","a22c61c3fdead3e958364786ffc796daaeeb918ca1033b8dc7228e8180a5859b","13c56286442af478c8b89b8d313f54b98fdf9ee0ddd0429b025c718913f92c96","a1cf51c368086658d473c0f8045b7fd5bf90178f7e4ce8926ec1b1e7d629b419"],"commitTx":"02000000000104b5215a023a50176369969d886fb32a40c0b883862ab750cd061ff339dda63a4500000000171600145c005c5532ce810ddf20f9d1d939631b47089ecdfdffffffd40825b8dca2dda833e9f653da0c2930611078099c959459eea92a9f86a4c8220000000000fdffffff8789f89f3e2e4e5015765b1b1382ad3aa634d2092785bcd5965699c25e206f3c00000000210357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2ffdffffff26bd8a346a51065b121a33830fbe2c7f2d3f8ddbc34318deb7e2a0dd48fa09aa0400000000fdffffff0550030000000000002251206ff0ac47ccff79fc3eaab0cd0047c28dead95cd35c6c695dfe33010b8807d16c3c03000000000000225120845a93ad3f2f36750672201709a48e6ad458cc0a42455f0786cf3bbbe42a6d183803000000000000225120be60aa4826e2e3a3245158c0e7b36543ed7ead2ed40a541c4583b80d4b3762003803000000000000225120e7ff49e9dee3ddaf3a811f12954a9c66cc98bf01c4eccb1ec093acf04ee2d1ff8262110000000000225120b7ee7f83a6a7fdb513040856c56778aa3abea9a451e0c9bb012f22a77ed99b2101210357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f01210357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f000000000000","revealTxs":["020000000001015c3a8f2abcd39b0e4a1fcf9fff905e17ed130fccd81a079271eb3f28e127a7e80000000000fdffffff012202000000000000225120b7ee7f83a6a7fdb513040856c56778aa3abea9a451e0c9bb012f22a77ed99b2103407d77a1c8dee85e59b2446f707e2e37aac600ce45cbb2ceb90554c8a391540de0c6df415177c65ab279b87abb29ca39fe6e07cae73fb9f726674e66412fd9b3bf7a2057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800347b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a22313030227d6821c057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000","020000000001015c3a8f2abcd39b0e4a1fcf9fff905e17ed130fccd81a079271eb3f28e127a7e80100000000fdffffff0122020000000000001976a9145c005c5532ce810ddf20f9d1d939631b47089ecd88ac0340e8d1b62dd426a98abe501dabf83969767d44a2c3542acf358a66d3dbbf5f6f8fa2144183fdead4e4a3e972cb522de94bfd12af4d940ac7694b90757d5651055a792057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800337b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a223130227d6821c157bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000","020000000001015c3a8f2abcd39b0e4a1fcf9fff905e17ed130fccd81a079271eb3f28e127a7e80200000000fdffffff0122020000000000001600145c005c5532ce810ddf20f9d1d939631b47089ecd0340ae4d6c59687a723c69a011253855f047481c309d084e783f80a2ea1df16190db8ce16598da992416b678b7b3626379184939f1cea45421e77b0a74b8fbecf23a7c2057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800367b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a223130303030227d6821c057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000","020000000001015c3a8f2abcd39b0e4a1fcf9fff905e17ed130fccd81a079271eb3f28e127a7e80300000000fdffffff01220200000000000017a914ef05515a0595d15eaf90d9f62fb85873a6d8c0b4870340ab4d04bbf1e15eb488229f074713de28cd0798cc4ce570bb0022106c97c2ba5fa8f80d15603d70a54470ba05887b05b01acbaca7b4ee5deaf6fd51846e95cfce782057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800327b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a2231227d6821c057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000"],"commitTxFee":1180,"revealTxFees":[302,282,278,278],"commitAddrs":["tb1pdlc2c37vlaulc042krxsq37z3h4djhxnt3kxjh07xvqshzq869kqz5sgrc","tb1ps3df8tfl9um82pnjyqtsnfywdt293nq2gfz47puxeuamhep2d5vq0jujz6","tb1phes25jpxut36xfz3trqw0vm9g0khatfw6s99g8z9swuq6jehvgqqdsrvg2","tb1pull5n6w7u0w67w5pruff2j5uvmxf30cpcnkvk8kqjwk0qnhz68ls68tklf"]}`
+	expected := `{"sigHashList":["89d176c6dd56cf7ac84c2b0136098c7394cdcb29318c8513092150af7f0ef685","a22c61c3fdead3e958364786ffc796daaeeb918ca1033b8dc7228e8180a5859b","13c56286442af478c8b89b8d313f54b98fdf9ee0ddd0429b025c718913f92c96","a1cf51c368086658d473c0f8045b7fd5bf90178f7e4ce8926ec1b1e7d629b419"],"commitTx":"02000000000104b5215a023a50176369969d886fb32a40c0b883862ab750cd061ff339dda63a4500000000171600145c005c5532ce810ddf20f9d1d939631b47089ecdfdffffffd40825b8dca2dda833e9f653da0c2930611078099c959459eea92a9f86a4c8220000000000fdffffff8789f89f3e2e4e5015765b1b1382ad3aa634d2092785bcd5965699c25e206f3c00000000210357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2ffdffffff26bd8a346a51065b121a33830fbe2c7f2d3f8ddbc34318deb7e2a0dd48fa09aa0400000000fdffffff0550030000000000002251206ff0ac47ccff79fc3eaab0cd0047c28dead95cd35c6c695dfe33010b8807d16c3c03000000000000225120845a93ad3f2f36750672201709a48e6ad458cc0a42455f0786cf3bbbe42a6d183803000000000000225120be60aa4826e2e3a3245158c0e7b36543ed7ead2ed40a541c4583b80d4b3762003803000000000000225120e7ff49e9dee3ddaf3a811f12954a9c66cc98bf01c4eccb1ec093acf04ee2d1ff8262110000000000225120b7ee7f83a6a7fdb513040856c56778aa3abea9a451e0c9bb012f22a77ed99b2101210357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f01210357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f000000000000","revealTxs":["020000000001015c3a8f2abcd39b0e4a1fcf9fff905e17ed130fccd81a079271eb3f28e127a7e80000000000fdffffff012202000000000000225120b7ee7f83a6a7fdb513040856c56778aa3abea9a451e0c9bb012f22a77ed99b2103407d77a1c8dee85e59b2446f707e2e37aac600ce45cbb2ceb90554c8a391540de0c6df415177c65ab279b87abb29ca39fe6e07cae73fb9f726674e66412fd9b3bf7a2057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800347b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a22313030227d6821c057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000","020000000001015c3a8f2abcd39b0e4a1fcf9fff905e17ed130fccd81a079271eb3f28e127a7e80100000000fdffffff0122020000000000001976a9145c005c5532ce810ddf20f9d1d939631b47089ecd88ac0340e8d1b62dd426a98abe501dabf83969767d44a2c3542acf358a66d3dbbf5f6f8fa2144183fdead4e4a3e972cb522de94bfd12af4d940ac7694b90757d5651055a792057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800337b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a223130227d6821c157bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000","020000000001015c3a8f2abcd39b0e4a1fcf9fff905e17ed130fccd81a079271eb3f28e127a7e80200000000fdffffff0122020000000000001600145c005c5532ce810ddf20f9d1d939631b47089ecd0340ae4d6c59687a723c69a011253855f047481c309d084e783f80a2ea1df16190db8ce16598da992416b678b7b3626379184939f1cea45421e77b0a74b8fbecf23a7c2057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800367b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a223130303030227d6821c057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000","020000000001015c3a8f2abcd39b0e4a1fcf9fff905e17ed130fccd81a079271eb3f28e127a7e80300000000fdffffff01220200000000000017a914ef05515a0595d15eaf90d9f62fb85873a6d8c0b4870340ab4d04bbf1e15eb488229f074713de28cd0798cc4ce570bb0022106c97c2ba5fa8f80d15603d70a54470ba05887b05b01acbaca7b4ee5deaf6fd51846e95cfce782057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2fac0063036f7264010118746578742f706c61696e3b636861727365743d7574662d3800327b2270223a226272632d3230222c226f70223a226d696e74222c227469636b223a2278637662222c22616d74223a2231227d6821c057bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f00000000"],"commitTxFee":1180,"revealTxFees":[302,282,278,278],"commitAddrs":["tb1pdlc2c37vlaulc042krxsq37z3h4djhxnt3kxjh07xvqshzq869kqz5sgrc","tb1ps3df8tfl9um82pnjyqtsnfywdt293nq2gfz47puxeuamhep2d5vq0jujz6","tb1phes25jpxut36xfz3trqw0vm9g0khatfw6s99g8z9swuq6jehvgqqdsrvg2","tb1pull5n6w7u0w67w5pruff2j5uvmxf30cpcnkvk8kqjwk0qnhz68ls68tklf"],"commitTxId":"e8a727e1283feb7192071ad8cc0f13ed175e90ff9fcf1f4a0e9bd3bc2a8f3a5c","revealTxIds":["b24a98e3ac49c76047ed14050995f7df7eba74f40da9971b50cfe4d285846376","9407c28e53ea4a88b8987a200b28908f57da33584f8416a71527becc999a2c52","55ab7a99229c93e092f322ab1fd289d7d279960b53b453567276d5013ce9b8a7","7ba4779b6da4580614ad5fb1098d2862caa6d15f8e0243bb4cfe48091cdcce0a"]}`
 	require.Equal(t, expected, string(rb))
 
 }
+
+func testInscriptionRequest() *InscriptionRequest {
+	privateKey := "cPnvkvUYyHcSSS26iD1dkrJdV7k1RoUqJLhn3CYxpo398PdLVE22"
+	commitTxPrevOutputList := []*PrevOutput{
+		{
+			TxId:       "453aa6dd39f31f06cd50b72a8683b8c0402ab36f889d96696317503a025a21b5",
+			VOut:       0,
+			Amount:     546,
+			Address:    "2NF33rckfiQTiE5Guk5ufUdwms8PgmtnEdc",
+			PrivateKey: privateKey,
+		},
+		{
+			TxId:       "22c8a4869f2aa9ee5994959c0978106130290cda53f6e933a8dda2dcb82508d4",
+			VOut:       0,
+			Amount:     546,
+			Address:    "tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc",
+			PrivateKey: privateKey,
+		},
+		{
+			TxId:       "3c6f205ec2995696d5bc852709d234a63aad82131b5b7615504e2e3e9ff88987",
+			VOut:       0,
+			Amount:     546,
+			Address:    "mouQtmBWDS7JnT65Grj2tPzdSmGKJgRMhE",
+			PrivateKey: privateKey,
+		},
+		{
+			TxId:       "aa09fa48dda0e2b7de1843c3db8d3f2d7f2cbe0f83331a125b06516a348abd26",
+			VOut:       4,
+			Amount:     1142196,
+			Address:    "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr",
+			PrivateKey: privateKey,
+		},
+	}
+	inscriptionDataList := []InscriptionData{
+		{
+			ContentType: "text/plain;charset=utf-8",
+			Body:        []byte(`{"p":"brc-20","op":"mint","tick":"xcvb","amt":"100"}`),
+			RevealAddr:  "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr",
+		},
+		{
+			ContentType: "text/plain;charset=utf-8",
+			Body:        []byte(`{"p":"brc-20","op":"mint","tick":"xcvb","amt":"10"}`),
+			RevealAddr:  "mouQtmBWDS7JnT65Grj2tPzdSmGKJgRMhE",
+		},
+	}
+	return &InscriptionRequest{
+		CommitTxPrevOutputList: commitTxPrevOutputList,
+		CommitFeeRate:          2,
+		RevealFeeRate:          2,
+		RevealOutValue:         546,
+		InscriptionDataList:    inscriptionDataList,
+		ChangeAddress:          "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr",
+	}
+}
+
+const (
+	testFundingAmount = int64(100000)
+	testFeeRate       = int64(2)
+)
+
+// buildTestInscription returns a request inscribing a short text with a freshly generated key.
+// The request is funded by a fabricated utxo of the taproot address of the key, which also
+// receives the inscription and the change, so it can be built on network right away.
+func buildTestInscription(t testing.TB, network *chaincfg.Params) *InscriptionRequest {
+	t.Helper()
+	privateKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	wif, err := btcutil.NewWIF(privateKey, network, true)
+	require.NoError(t, err)
+	_, _, _, address, err := AllAddresses(privateKey.PubKey(), network)
+	require.NoError(t, err)
+	pubKey := privateKey.PubKey().SerializeCompressed()
+	fundingTxHash := sha256.Sum256(pubKey)
+
+	return &InscriptionRequest{
+		CommitTxPrevOutputList: []*PrevOutput{
+			{
+				TxId:       hex.EncodeToString(fundingTxHash[:]),
+				VOut:       0,
+				Amount:     testFundingAmount,
+				Address:    address,
+				PrivateKey: wif.String(),
+				PublicKey:  hex.EncodeToString(pubKey),
+			},
+		},
+		CommitFeeRate: testFeeRate,
+		RevealFeeRate: testFeeRate,
+		InscriptionDataList: []InscriptionData{
+			{
+				ContentType: "text/plain;charset=utf-8",
+				Body:        []byte("Hello World"),
+				RevealAddr:  address,
+			},
+		},
+		RevealOutValue: DefaultRevealOutValue,
+		ChangeAddress:  address,
+	}
+}
+
+func TestBuildTestInscription(t *testing.T) {
+	network := &chaincfg.RegressionNetParams
+	request := buildTestInscription(t, network)
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+	require.Len(t, tool.RevealTx, 1)
+	require.Equal(t, testFundingAmount, tool.CommitTotalInput())
+}
+
+func TestInscriptionBuilder_TotalVSize(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	tool, err := NewInscriptionTool(network, testInscriptionRequest())
+	require.NoError(t, err)
+
+	commitTxHex, err := tool.GetCommitTxHex()
+	require.NoError(t, err)
+	revealTxHexList, err := tool.GetRevealTxHexList()
+	require.NoError(t, err)
+
+	commitTx, err := NewTxFromHex(commitTxHex)
+	require.NoError(t, err)
+	expected := GetTxVirtualSize(btcutil.NewTx(commitTx))
+	for _, revealTxHex := range revealTxHexList {
+		revealTx, err := NewTxFromHex(revealTxHex)
+		require.NoError(t, err)
+		expected += GetTxVirtualSize(btcutil.NewTx(revealTx))
+	}
+	require.Equal(t, expected, tool.TotalVSize())
+	require.Greater(t, tool.TotalVSize(), GetTxVirtualSize(btcutil.NewTx(commitTx)))
+}
+
+func TestGetDustThreshold(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	tests := []struct {
+		address string
+		dust    int64
+	}{
+		{"mouQtmBWDS7JnT65Grj2tPzdSmGKJgRMhE", 546},
+		{"2NF33rckfiQTiE5Guk5ufUdwms8PgmtnEdc", 540},
+		{"tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc", 294},
+		{"tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr", 330},
+	}
+	for _, test := range tests {
+		pkScript, err := AddrToPkScript(test.address, network)
+		require.NoError(t, err)
+		require.Equal(t, test.dust, GetDustThreshold(pkScript), test.address)
+	}
+}
+
+func TestInscribe_RevealOutValueBelowDust(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.RevealOutValue = 100
+
+	_, err := Inscribe(network, request)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "below the dust threshold")
+
+	_, err = InscribeForMPCUnsigned(request, network, nil, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "below the dust threshold")
+}
+
+func TestInscribe_CommitOutputsCoverRevealFee(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+
+	// a zero postage is never built, it falls back to DefaultRevealOutValue
+	request.RevealOutValue = 0
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	for i, revealTx := range tool.RevealTx {
+		commitOutput := tool.CommitTx.TxOut[tool.InscriptionTxCtxDataList[i].CommitTxOutIndex]
+		require.Equal(t, DefaultRevealOutValue, revealTx.TxOut[0].Value)
+		require.Equal(t, DefaultRevealOutValue+tool.MustRevealTxFees[i], commitOutput.Value)
+	}
+
+	// a postage left below dust after the reveal fee is rejected
+	request.RevealOutValue = 1
+	_, err = NewInscriptionTool(network, request)
+	require.ErrorContains(t, err, "reveal(index 0) output value 1 is below the dust threshold")
+	_, err = InscribeForMPCUnsigned(request, network, nil, nil)
+	require.ErrorContains(t, err, "reveal(index 0) output value 1 is below the dust threshold")
+
+	// unless dust is allowed, the commit output still pays the whole reveal fee on top of it
+	request.AllowDust = true
+	tool, err = NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	for i, revealTx := range tool.RevealTx {
+		commitOutput := tool.CommitTx.TxOut[tool.InscriptionTxCtxDataList[i].CommitTxOutIndex]
+		require.Equal(t, int64(1), revealTx.TxOut[0].Value)
+		require.Equal(t, 1+tool.MustRevealTxFees[i], commitOutput.Value)
+	}
+}
+
+type mockBroadcaster struct {
+	txs []string
+}
+
+func (b *mockBroadcaster) Broadcast(txHex string) (string, error) {
+	tx, err := NewTxFromHex(txHex)
+	if err != nil {
+		return "", err
+	}
+	b.txs = append(b.txs, txHex)
+	return tx.TxHash().String(), nil
+}
+
+func TestInscribeAndBroadcast(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	broadcaster := &mockBroadcaster{}
+	txs, err := InscribeAndBroadcast(network, testInscriptionRequest(), broadcaster)
+	require.NoError(t, err)
+
+	require.Equal(t, 1+len(txs.RevealTxs), len(broadcaster.txs))
+	require.Equal(t, txs.CommitTx, broadcaster.txs[0])
+	for i, revealTx := range txs.RevealTxs {
+		require.Equal(t, revealTx, broadcaster.txs[i+1])
+	}
+}
+
+func TestInscribe_SacrificeExcessToFee(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.MinChangeValue = 10000000
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Equal(t, len(request.InscriptionDataList), len(tool.CommitTx.TxOut))
+	sacrificedFee, _ := tool.CalculateFee()
+
+	sacrifice := false
+	request.SacrificeExcessToFee = &sacrifice
+	tool, err = NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Equal(t, len(request.InscriptionDataList)+1, len(tool.CommitTx.TxOut))
+	commitTxFee, _ := tool.CalculateFee()
+	changeValue := tool.CommitTx.TxOut[len(tool.CommitTx.TxOut)-1].Value
+	require.Less(t, changeValue, request.MinChangeValue)
+	require.Less(t, commitTxFee, sacrificedFee)
+}
+
+func TestInscribe_RevealFeeProportionalToSize(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.RevealFeeRate = 3
+	request.InscriptionDataList[0].Body = []byte("small")
+	request.InscriptionDataList[1].Body = bytes.Repeat([]byte("large"), 1000)
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	_, revealTxFees := tool.CalculateFee()
+	require.Equal(t, 2, len(revealTxFees))
+	require.Greater(t, revealTxFees[1], revealTxFees[0]*5)
+	for i, tx := range tool.RevealTx {
+		require.Equal(t, GetTxVirtualSize(btcutil.NewTx(tx))*request.RevealFeeRate, revealTxFees[i])
+	}
+}
+
+func TestInscribe_TaprootCommitFeeEstimate(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := buildTestInscription(t, network)
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	commitTxFee, _ := tool.CalculateFee()
+	vSize := GetTxVirtualSize(btcutil.NewTx(tool.CommitTx))
+	require.LessOrEqual(t, commitTxFee-vSize*request.CommitFeeRate, request.CommitFeeRate)
+	require.GreaterOrEqual(t, commitTxFee, vSize*request.CommitFeeRate)
+
+	res, err := InscribeForMPCUnsigned(request, network, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, commitTxFee, res.CommitTxFee)
+}
+
+func TestInscribeForMPCSigned_LegacyCommitInput(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.CommitTxPrevOutputList = []*PrevOutput{{
+		TxId:       "3c6f205ec2995696d5bc852709d234a63aad82131b5b7615504e2e3e9ff88987",
+		VOut:       1,
+		Amount:     100000,
+		Address:    "mouQtmBWDS7JnT65Grj2tPzdSmGKJgRMhE",
+		PrivateKey: "cPnvkvUYyHcSSS26iD1dkrJdV7k1RoUqJLhn3CYxpo398PdLVE22",
+		PublicKey:  "0357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f",
+	}}
+
+	unsigned, err := InscribeForMPCUnsigned(request, network, nil, nil)
+	require.NoError(t, err)
+	wif, err := btcutil.DecodeWIF(request.CommitTxPrevOutputList[0].PrivateKey)
+	require.NoError(t, err)
+	signatures := make([]string, len(unsigned.SigHashList))
+	for i, sigHash := range unsigned.SigHashList {
+		hash, err := hex.DecodeString(sigHash)
+		require.NoError(t, err)
+		sig, err := ecdsa.SignCompact(wif.PrivKey, hash, true)
+		require.NoError(t, err)
+		signatures[i] = hex.EncodeToString(sig[1:])
+	}
+
+	signed, err := InscribeForMPCSigned(request, network, unsigned.CommitTx, signatures)
+	require.NoError(t, err)
+	unsignedCommitTx, err := NewTxFromHex(unsigned.CommitTx)
+	require.NoError(t, err)
+	signedCommitTx, err := NewTxFromHex(signed.CommitTx)
+	require.NoError(t, err)
+	require.NotEqual(t, unsignedCommitTx.TxHash(), signedCommitTx.TxHash())
+	require.Equal(t, unsignedCommitTx.TxHash().String(), unsigned.CommitTxId)
+	require.Equal(t, signedCommitTx.TxHash().String(), signed.CommitTxId)
+	require.Len(t, signed.RevealTxIds, len(signed.RevealTxs))
+	for i, revealTxHex := range signed.RevealTxs {
+		revealTx, err := NewTxFromHex(revealTxHex)
+		require.NoError(t, err)
+		require.Equal(t, revealTx.TxHash().String(), signed.RevealTxIds[i])
+		require.Equal(t, signed.CommitTxId, revealTx.TxIn[0].PreviousOutPoint.Hash.String())
+	}
+
+	pkScript, err := AddrToPkScript(request.CommitTxPrevOutputList[0].Address, network)
+	require.NoError(t, err)
+	commitPrevOutFetcher := txscript.NewCannedPrevOutputFetcher(pkScript, request.CommitTxPrevOutputList[0].Amount)
+	vm, err := txscript.NewEngine(pkScript, signedCommitTx, 0, txscript.StandardVerifyFlags, nil, nil, request.CommitTxPrevOutputList[0].Amount, commitPrevOutFetcher)
+	require.NoError(t, err)
+	require.NoError(t, vm.Execute())
+
+	for i, revealTxHex := range signed.RevealTxs {
+		revealTx, err := NewTxFromHex(revealTxHex)
+		require.NoError(t, err)
+		require.Equal(t, signedCommitTx.TxHash(), revealTx.TxIn[0].PreviousOutPoint.Hash)
+		prevOut := signedCommitTx.TxOut[revealTx.TxIn[0].PreviousOutPoint.Index]
+		revealPrevOutFetcher := txscript.NewCannedPrevOutputFetcher(prevOut.PkScript, prevOut.Value)
+		vm, err := txscript.NewEngine(prevOut.PkScript, revealTx, 0, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(revealTx, revealPrevOutFetcher), prevOut.Value, revealPrevOutFetcher)
+		require.NoError(t, err)
+		require.NoError(t, vm.Execute(), "reveal %d", i)
+	}
+}
+
+func TestInscribe_ConsolidationSuggestion(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	commitTxPrevOutputList := make([]*PrevOutput, 50)
+	totalAmount := int64(0)
+	for i := range commitTxPrevOutputList {
+		commitTxPrevOutputList[i] = &PrevOutput{
+			TxId:       "22c8a4869f2aa9ee5994959c0978106130290cda53f6e933a8dda2dcb82508d4",
+			VOut:       uint32(i),
+			Amount:     1000,
+			Address:    "tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc",
+			PrivateKey: "cPnvkvUYyHcSSS26iD1dkrJdV7k1RoUqJLhn3CYxpo398PdLVE22",
+		}
+		totalAmount += 1000
+	}
+	request.CommitTxPrevOutputList = commitTxPrevOutputList
+	request.MaxCommitInputs = 20
+
+	// only the few inputs covering the default postage are spent
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Less(t, len(tool.CommitTx.TxIn), 20)
+
+	// a large postage needs more than 20 of the dust inputs
+	request.RevealOutValue = 15000
+	_, err = Inscribe(network, request)
+	require.Error(t, err)
+	var suggestion *ConsolidationSuggestion
+	require.True(t, errors.As(err, &suggestion))
+	require.Greater(t, len(suggestion.Inputs), 20)
+	require.Less(t, len(suggestion.Inputs), 50)
+	require.Equal(t, request.ChangeAddress, suggestion.Address)
+	require.Greater(t, suggestion.Fee, int64(0))
+	require.Equal(t, int64(len(suggestion.Inputs))*1000, suggestion.Amount+suggestion.Fee)
+
+	request.MaxCommitInputs = 40
+	tool, err = NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Len(t, tool.CommitTx.TxIn, len(suggestion.Inputs))
+}
+
+func TestInscriptionBuilder_BuildManifest(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+
+	manifestBytes, err := tool.BuildManifest()
+	require.NoError(t, err)
+	var manifest []InscriptionManifestItem
+	require.NoError(t, json.Unmarshal(manifestBytes, &manifest))
+	require.Equal(t, len(request.InscriptionDataList), len(manifest))
+	for i, item := range manifest {
+		bodyHash := sha256.Sum256(request.InscriptionDataList[i].Body)
+		require.Equal(t, i, item.Index)
+		require.Equal(t, tool.RevealTx[i].TxHash().String()+"i0", item.InscriptionId)
+		require.Equal(t, request.InscriptionDataList[i].ContentType, item.ContentType)
+		require.Equal(t, hex.EncodeToString(bodyHash[:]), item.Sha256)
+	}
+}
+
+func TestCheckRevealPrevOutputValue(t *testing.T) {
+	pkScript, err := AddrToPkScript("tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr", &chaincfg.TestNet3Params)
+	require.NoError(t, err)
+
+	err = checkRevealPrevOutputValue(0, 300, 300, pkScript, false)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "does not cover the reveal fee")
+
+	err = checkRevealPrevOutputValue(1, 600, 300, pkScript, false)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "below the dust threshold")
+
+	require.NoError(t, checkRevealPrevOutputValue(2, 630, 300, pkScript, false))
+}
+
+func TestComputeFee(t *testing.T) {
+	tests := []struct {
+		weight int64
+		mode   RoundingMode
+		fee    int64
+	}{
+		{400, RoundUp, 200},
+		{400, RoundDown, 200},
+		{400, RoundNearest, 200},
+		{401, RoundUp, 202},
+		{401, RoundDown, 200},
+		{401, RoundNearest, 200},
+		{402, RoundUp, 202},
+		{402, RoundDown, 200},
+		{402, RoundNearest, 202},
+		{403, RoundUp, 202},
+		{403, RoundDown, 200},
+		{403, RoundNearest, 202},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.fee, computeFee(test.weight, 2, test.mode), "weight %d mode %d", test.weight, test.mode)
+	}
+}
+
+func TestInscribe_FeeRoundingMode(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	_, roundUpFees := tool.CalculateFee()
+
+	request.FeeRoundingMode = RoundDown
+	tool, err = NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	_, roundDownFees := tool.CalculateFee()
+	for i, tx := range tool.RevealTx {
+		require.Equal(t, GetTransactionWeight(btcutil.NewTx(tx))/WitnessScaleFactor*request.RevealFeeRate, roundDownFees[i])
+		require.LessOrEqual(t, roundDownFees[i], roundUpFees[i])
+	}
+}
+
+func verifyTxInputs(t *testing.T, tx *wire.MsgTx, prevOutFetcher txscript.PrevOutputFetcher) {
+	txSigHashes := txscript.NewTxSigHashes(tx, prevOutFetcher)
+	for i, in := range tx.TxIn {
+		prevOut := prevOutFetcher.FetchPrevOutput(in.PreviousOutPoint)
+		require.NotNil(t, prevOut)
+		vm, err := txscript.NewEngine(prevOut.PkScript, tx, i, txscript.StandardVerifyFlags, nil, txSigHashes, prevOut.Value, prevOutFetcher)
+		require.NoError(t, err)
+		require.NoError(t, vm.Execute(), "input %d", i)
+	}
+}
+
+func TestInscribe_RevealInternalKey(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	defaultTool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+
+	request.RevealInternalKey = "1790962db820729606cd7b255ace1ac5ebb129ac8e9b2d8534d022194ab25b37"
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NotEqual(t, defaultTool.CommitAddrs, tool.CommitAddrs)
+	for _, tx := range tool.RevealTx {
+		verifyTxInputs(t, tx, tool.RevealTxPrevOutputFetcher)
+	}
+
+	again, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Equal(t, tool.CommitAddrs, again.CommitAddrs)
+
+	for _, prevOutput := range request.CommitTxPrevOutputList {
+		prevOutput.PublicKey = "0357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f"
+	}
+	res, err := InscribeForMPCUnsigned(request, network, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, tool.CommitAddrs, res.CommitAddrs)
+
+	request.RevealInternalKey = "not a key"
+	_, err = NewInscriptionTool(network, request)
+	require.Error(t, err)
+}
+
+func TestInscribe_TxIds(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	txs, err := Inscribe(network, testInscriptionRequest())
+	require.NoError(t, err)
+
+	commitTx, err := NewTxFromHex(txs.CommitTx)
+	require.NoError(t, err)
+	require.Equal(t, commitTx.TxHash().String(), txs.CommitTxId)
+	require.NotEqual(t, commitTx.WitnessHash().String(), txs.CommitTxId)
+	require.Equal(t, len(txs.RevealTxs), len(txs.RevealTxIds))
+	for i, revealTxHex := range txs.RevealTxs {
+		revealTx, err := NewTxFromHex(revealTxHex)
+		require.NoError(t, err)
+		require.Equal(t, revealTx.TxHash().String(), txs.RevealTxIds[i])
+		require.Equal(t, txs.CommitTxId, revealTx.TxIn[0].PreviousOutPoint.Hash.String())
+	}
+}
+
+func TestInscribe_CommitFundingVout(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.InscriptionDataList[0].CommitFundingVout = 1
+	request.InscriptionDataList[1].CommitFundingVout = 0
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	for i, tx := range tool.RevealTx {
+		vout := uint32(request.InscriptionDataList[i].CommitFundingVout)
+		require.Equal(t, tool.CommitTx.TxHash(), tx.TxIn[0].PreviousOutPoint.Hash)
+		require.Equal(t, vout, tx.TxIn[0].PreviousOutPoint.Index)
+		require.Equal(t, tool.InscriptionTxCtxDataList[i].CommitTxAddressPkScript, tool.CommitTx.TxOut[vout].PkScript)
+		verifyTxInputs(t, tx, txscript.NewCannedPrevOutputFetcher(tool.CommitTx.TxOut[vout].PkScript, tool.CommitTx.TxOut[vout].Value))
+	}
+
+	request.InscriptionDataList[0].CommitFundingVout = 1
+	request.InscriptionDataList[1].CommitFundingVout = 1
+	_, err = NewInscriptionTool(network, request)
+	require.Error(t, err)
+}
+
+func TestInscriptionBuilder_MinRelayablePackageRate(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	tool, err := NewInscriptionTool(network, testInscriptionRequest())
+	require.NoError(t, err)
+
+	require.Equal(t, MinRelayFeeRate, tool.MinRelayablePackageRate(0))
+	require.Equal(t, MinRelayFeeRate, tool.MinRelayablePackageRate(1000))
+	require.Equal(t, int64(2), tool.MinRelayablePackageRate(1001))
+	require.Equal(t, int64(2), tool.MinRelayablePackageRate(1500))
+	require.Equal(t, int64(25), tool.MinRelayablePackageRate(25000))
+
+	rate := tool.MinRelayablePackageRate(12345)
+	require.GreaterOrEqual(t, rate*tool.TotalVSize()*1000, 12345*tool.TotalVSize())
+	require.Less(t, (rate-1)*tool.TotalVSize()*1000, 12345*tool.TotalVSize())
+}
+
+func TestInscriptionBuilder_BatchRevealSigning(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.InscriptionDataList = append(request.InscriptionDataList, InscriptionData{
+		ContentType: "text/plain;charset=utf-8",
+		Body:        []byte(`{"p":"brc-20","op":"mint","tick":"xcvb","amt":"1"}`),
+		RevealAddr:  "tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc",
+	})
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+
+	batchRevealTx := wire.NewMsgTx(DefaultTxVersion)
+	for _, tx := range tool.RevealTx {
+		batchRevealTx.AddTxIn(tx.TxIn[0])
+		batchRevealTx.AddTxOut(tx.TxOut[0])
+	}
+	tool.RevealTx = []*wire.MsgTx{batchRevealTx}
+	require.NoError(t, tool.completeRevealTx(context.Background()))
+
+	require.Equal(t, 3, len(batchRevealTx.TxIn))
+	for j, in := range batchRevealTx.TxIn {
+		require.Equal(t, tool.InscriptionTxCtxDataList[j].InscriptionScript, []byte(in.Witness[1]))
+		require.Equal(t, tool.InscriptionTxCtxDataList[j].ControlBlockWitness, []byte(in.Witness[2]))
+	}
+	verifyTxInputs(t, batchRevealTx, tool.RevealTxPrevOutputFetcher)
+}
+
+func TestInscriptionBuilder_DependencyGraph(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	tool, err := NewInscriptionTool(network, testInscriptionRequest())
+	require.NoError(t, err)
+
+	commitTxId := tool.CommitTx.TxHash().String()
+	graph := tool.DependencyGraph()
+	require.Equal(t, 1+len(tool.RevealTx), len(graph))
+	require.Empty(t, graph[commitTxId])
+	for _, tx := range tool.RevealTx {
+		require.Equal(t, []string{commitTxId}, graph[tx.TxHash().String()])
+	}
+
+	// the child reveal spends the parent inscription, which is the output of the first reveal
+	parentTx, childTx := tool.RevealTx[0], tool.RevealTx[1]
+	parentTxHash := parentTx.TxHash()
+	childTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&parentTxHash, 0), nil, nil))
+	graph = tool.DependencyGraph()
+	require.Equal(t, []string{commitTxId}, graph[parentTxHash.String()])
+	require.Equal(t, []string{commitTxId, parentTxHash.String()}, graph[childTx.TxHash().String()])
+}
+
+func TestNewInscriptionTool_WIFNetworkMismatch(t *testing.T) {
+	_, err := NewInscriptionTool(&chaincfg.MainNetParams, testInscriptionRequest())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not for network mainnet")
+
+	_, err = NewInscriptionTool(&chaincfg.MainNetParams, buildTestInscription(t, &chaincfg.MainNetParams))
+	require.NoError(t, err)
+
+	_, err = NewInscriptionTool(&chaincfg.TestNet3Params, testInscriptionRequest())
+	require.NoError(t, err)
+}
+
+func TestInscribe_FeeBufferPercent(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+
+	request.FeeBufferPercent = 5
+	bufferedTool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	for i, fee := range tool.MustRevealTxFees {
+		require.Equal(t, fee+(fee*5+99)/100, bufferedTool.MustRevealTxFees[i])
+	}
+	commitTxFee, _ := tool.CalculateFee()
+	bufferedCommitTxFee, _ := bufferedTool.CalculateFee()
+	require.Greater(t, bufferedCommitTxFee, commitTxFee)
+	require.Equal(t, int64(105), applyFeeBuffer(100, 5))
+	require.Equal(t, int64(103), applyFeeBuffer(101, 1))
+	require.Equal(t, int64(101), applyFeeBuffer(101, 0))
+}
+
+func TestRestoreBuilder(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	commitTx, err := tool.GetCommitTxHex()
+	require.NoError(t, err)
+	revealTxs, err := tool.GetRevealTxHexList()
+	require.NoError(t, err)
+
+	restored, err := RestoreBuilder(network, request, commitTx, revealTxs)
+	require.NoError(t, err)
+	commitTxFee, revealTxFees := tool.CalculateFee()
+	restoredCommitTxFee, restoredRevealTxFees := restored.CalculateFee()
+	require.Equal(t, commitTxFee, restoredCommitTxFee)
+	require.Equal(t, revealTxFees, restoredRevealTxFees)
+	require.Equal(t, tool.InscriptionIDs(), restored.InscriptionIDs())
+	require.Equal(t, tool.CommitAddrs, restored.CommitAddrs)
+
+	_, err = RestoreBuilder(network, request, commitTx, revealTxs[:1])
+	require.Error(t, err)
+}
+
+func TestInscribe_ContentTypeWarning(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.InscriptionDataList[0].ContentType = "image/png"
+	request.InscriptionDataList[0].Body = append([]byte{0xFF, 0xD8, 0xFF, 0xE0}, []byte("JFIF")...)
+	request.InscriptionDataList[1].ContentType = "text/plain;charset=utf-8"
+	request.InscriptionDataList[1].Body = []byte(`{"p":"brc-20","op":"mint","tick":"xcvb","amt":"100"}`)
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Equal(t, []string{"inscription(index 0) declares content type image/png but its body looks like image/jpeg"}, tool.Warnings())
+
+	require.Empty(t, contentTypeWarning(0, InscriptionData{ContentType: "application/json", Body: []byte(`{"a":1}`)}))
+	require.Empty(t, contentTypeWarning(0, InscriptionData{ContentType: "image/png", Body: []byte{0x01, 0x02}}))
+}
+
+func TestInscriptionBuilder_RevealSigHashes(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	revealTxs, err := tool.GetRevealTxHexList()
+	require.NoError(t, err)
+
+	sigHashes, err := tool.RevealSigHashes()
+	require.NoError(t, err)
+	require.Len(t, sigHashes, len(request.InscriptionDataList))
+	privateKeyWif, err := btcutil.DecodeWIF(request.CommitTxPrevOutputList[0].PrivateKey)
+	require.NoError(t, err)
+	sigs := make([][]byte, len(sigHashes))
+	for i, sigHash := range sigHashes {
+		signature, err := schnorr.Sign(privateKeyWif.PrivKey, sigHash)
+		require.NoError(t, err)
+		sigs[i] = signature.Serialize()
+	}
+	for _, tx := range tool.RevealTx {
+		tx.TxIn[0].Witness = nil
+	}
+	require.NoError(t, tool.ApplyRevealSignatures(sigs))
+	for _, tx := range tool.RevealTx {
+		verifyTxInputs(t, tx, tool.RevealTxPrevOutputFetcher)
+	}
+	appliedRevealTxs, err := tool.GetRevealTxHexList()
+	require.NoError(t, err)
+	require.Equal(t, revealTxs, appliedRevealTxs)
+
+	sigs[0], sigs[1] = sigs[1], sigs[0]
+	require.Error(t, tool.ApplyRevealSignatures(sigs))
+	require.Error(t, tool.ApplyRevealSignatures(sigs[:1]))
+}
+
+func TestOrdTag(t *testing.T) {
+	require.Equal(t, OrdTag(1), TagContentType)
+	require.Equal(t, OrdTag(2), TagPointer)
+	require.Equal(t, OrdTag(3), TagParent)
+	require.Equal(t, OrdTag(5), TagMetadata)
+	require.Equal(t, OrdTag(7), TagMetaprotocol)
+	require.Equal(t, OrdTag(9), TagContentEncoding)
+	require.Equal(t, OrdTag(11), TagDelegate)
+}
+
+func TestInscribe_ExactRevealPostage(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.FeeBufferPercent = 10
+	request.ExactRevealPostage = true
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	_, revealTxFees := tool.CalculateFee()
+	for i, tx := range tool.RevealTx {
+		require.Len(t, tx.TxOut, 1)
+		require.Equal(t, request.RevealOutValue, tx.TxOut[0].Value)
+		require.Equal(t, GetTxVirtualSize(btcutil.NewTx(tx))*request.RevealFeeRate, revealTxFees[i])
+		require.Equal(t, request.RevealOutValue+revealTxFees[i], tool.CommitTx.TxOut[i].Value)
+	}
+}
+
+func TestNetworkDefaults(t *testing.T) {
+	mainNetRate, mainNetDust := NetworkDefaults(&chaincfg.MainNetParams)
+	regTestRate, regTestDust := NetworkDefaults(&chaincfg.RegressionNetParams)
+	require.Equal(t, MainNetMinFeeRate, mainNetRate)
+	require.Equal(t, DefaultMinChangeValue, mainNetDust)
+	require.Equal(t, MinRelayFeeRate, regTestRate)
+	require.Equal(t, DevNetDustValue, regTestDust)
+	require.NotEqual(t, mainNetRate, regTestRate)
+	require.NotEqual(t, mainNetDust, regTestDust)
+
+	request := testInscriptionRequest()
+	request.RevealFeeRate = 0
+	commitFeeRate, revealFeeRates, err := normalizeFeeRates(&chaincfg.TestNet3Params, request)
+	require.NoError(t, err)
+	require.Equal(t, request.CommitFeeRate, commitFeeRate)
+	require.Equal(t, []int64{MinRelayFeeRate, MinRelayFeeRate}, revealFeeRates)
+}
+
+func TestNormalizeFeeRates(t *testing.T) {
+	request := testInscriptionRequest()
+	request.CommitFeeRate = 0
+	request.RevealFeeRate = 0
+
+	// network default when unset
+	commitFeeRate, revealFeeRates, err := normalizeFeeRates(&chaincfg.MainNetParams, request)
+	require.NoError(t, err)
+	require.Equal(t, MainNetMinFeeRate, commitFeeRate)
+	require.Equal(t, []int64{MainNetMinFeeRate, MainNetMinFeeRate}, revealFeeRates)
+
+	// the request rate takes precedence over the network default, even below it
+	request.CommitFeeRate = 1
+	request.RevealFeeRate = 7
+	commitFeeRate, revealFeeRates, err = normalizeFeeRates(&chaincfg.MainNetParams, request)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), commitFeeRate)
+	require.Equal(t, []int64{7, 7}, revealFeeRates)
+
+	request.RevealFeeRate = -1
+	_, _, err = normalizeFeeRates(&chaincfg.MainNetParams, request)
+	require.Error(t, err)
+}
+
+func TestContentCategory(t *testing.T) {
+	tests := []struct {
+		contentType string
+		category    string
+	}{
+		{"image/png", "image"},
+		{"image/svg+xml", "image"},
+		{"text/html;charset=utf-8", "text"},
+		{"application/json", "text"},
+		{"model/gltf-binary", "model"},
+		{"video/mp4", "video"},
+		{"application/octet-stream", "other"},
+		{"", "other"},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.category, ContentCategory(test.contentType), test.contentType)
+	}
+}
+
+// run with -race to check the read accessors do not modify the builder
+func TestInscriptionBuilder_ConcurrentReads(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	tool, err := NewInscriptionTool(network, testInscriptionRequest())
+	require.NoError(t, err)
+	commitTx, err := tool.GetCommitTxHex()
+	require.NoError(t, err)
+	revealTxs, err := tool.GetRevealTxHexList()
+	require.NoError(t, err)
+	commitTxFee, revealTxFees := tool.CalculateFee()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			readCommitTx, err := tool.GetCommitTxHex()
+			require.NoError(t, err)
+			require.Equal(t, commitTx, readCommitTx)
+			readRevealTxs, err := tool.GetRevealTxHexList()
+			require.NoError(t, err)
+			require.Equal(t, revealTxs, readRevealTxs)
+			readCommitTxFee, readRevealTxFees := tool.CalculateFee()
+			require.Equal(t, commitTxFee, readCommitTxFee)
+			require.Equal(t, revealTxFees, readRevealTxFees)
+			_, err = tool.RevealSigHashes()
+			require.NoError(t, err)
+			_, err = tool.BuildManifest()
+			require.NoError(t, err)
+			tool.Warnings()
+			tool.TotalVSize()
+			tool.DependencyGraph()
+			tool.MinRelayablePackageRate(1000)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestInscriptionBuilder_WouldExceedAncestorLimit(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.False(t, tool.WouldExceedAncestorLimit())
+
+	inscriptionData := request.InscriptionDataList[0]
+	request.InscriptionDataList = nil
+	for i := 0; i < 30; i++ {
+		request.InscriptionDataList = append(request.InscriptionDataList, inscriptionData)
+	}
+	_, err = NewInscriptionTool(network, request)
+	require.ErrorIs(t, err, ErrAncestorLimitExceeded)
+	require.ErrorContains(t, err, "30 reveal txs spend the commit tx but only 24 can be relayed")
+
+	request.IgnoreAncestorLimit = true
+	tool, err = NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Len(t, tool.RevealTx, 30)
+	require.True(t, tool.WouldExceedAncestorLimit())
+}
+
+func TestInscriptionBuilder_TapMerkleRoots(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	privateKeyWif, err := btcutil.DecodeWIF(request.CommitTxPrevOutputList[0].PrivateKey)
+	require.NoError(t, err)
+
+	roots := tool.TapMerkleRoots()
+	require.Len(t, roots, len(request.InscriptionDataList))
+	for i, root := range roots {
+		outputKey := txscript.ComputeTaprootOutputKey(privateKeyWif.PrivKey.PubKey(), root)
+		addr, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), network)
+		require.NoError(t, err)
+		pkScript, err := txscript.PayToAddrScript(addr)
+		require.NoError(t, err)
+		require.Equal(t, tool.CommitTx.TxOut[i].PkScript, pkScript)
+	}
+}
+
+func TestInscribe_RevealInputsWithAndWithoutChange(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.CommitTxPrevOutputList = request.CommitTxPrevOutputList[3:]
+
+	assertRevealInputs := func(tool *InscriptionBuilder) {
+		commitTxHash := tool.CommitTx.TxHash()
+		for i, tx := range tool.RevealTx {
+			require.Len(t, tx.TxIn, 1)
+			require.Equal(t, commitTxHash, tx.TxIn[0].PreviousOutPoint.Hash)
+			require.Equal(t, uint32(i), tx.TxIn[0].PreviousOutPoint.Index)
+			require.Equal(t, tool.InscriptionTxCtxDataList[i].CommitTxAddressPkScript, tool.CommitTx.TxOut[i].PkScript)
+			verifyTxInputs(t, tx, tool.RevealTxPrevOutputFetcher)
+		}
+	}
+
+	// over funded, the change output comes after the inscription outputs
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Len(t, tool.CommitTx.TxOut, len(request.InscriptionDataList)+1)
+	assertRevealInputs(tool)
+
+	// exactly funded, there is no change output
+	totalRevealPrevOutputValue := int64(0)
+	for _, out := range tool.CommitTx.TxOut[:len(request.InscriptionDataList)] {
+		totalRevealPrevOutputValue += out.Value
+	}
+	commitTxFee, _ := tool.CalculateFee()
+	request.CommitTxPrevOutputList[0].Amount = totalRevealPrevOutputValue + commitTxFee
+	tool, err = NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Len(t, tool.CommitTx.TxOut, len(request.InscriptionDataList))
+	assertRevealInputs(tool)
+}
+
+func TestNewInscriptionTool_PrevOutputKeyMismatch(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	otherKeyBytes, err := hex.DecodeString("1790962db820729606cd7b255ace1ac5ebb129ac8e9b2d8534d022194ab25b37")
+	require.NoError(t, err)
+	otherKey, _ := btcec.PrivKeyFromBytes(otherKeyBytes)
+	otherWif, err := btcutil.NewWIF(otherKey, network, true)
+	require.NoError(t, err)
+	request.CommitTxPrevOutputList[1].PrivateKey = otherWif.String()
+
+	_, err = NewInscriptionTool(network, request)
+	require.EqualError(t, err, "private key of commit input 1 does not match its address tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc")
+}
+
+func TestSigHashCache(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	revealTxs, err := tool.GetRevealTxHexList()
+	require.NoError(t, err)
+
+	request.SigHashCache = NewSigHashCache()
+	for i := 0; i < 2; i++ {
+		cachedTool, err := NewInscriptionTool(network, request)
+		require.NoError(t, err)
+		cachedRevealTxs, err := cachedTool.GetRevealTxHexList()
+		require.NoError(t, err)
+		require.Equal(t, revealTxs, cachedRevealTxs)
+		require.Len(t, request.SigHashCache.hashes, len(revealTxs))
+	}
+}
+
+func BenchmarkInscribe_Rebuild(b *testing.B) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	for i := 0; i < 22; i++ {
+		request.InscriptionDataList = append(request.InscriptionDataList, request.InscriptionDataList[i%2])
+	}
+	b.Run("NoCache", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := NewInscriptionTool(network, request); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Cache", func(b *testing.B) {
+		cachedRequest := *request
+		cachedRequest.SigHashCache = NewSigHashCache()
+		for i := 0; i < b.N; i++ {
+			if _, err := NewInscriptionTool(network, &cachedRequest); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkRevealSigHashes_Batch(b *testing.B) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	for i := 0; i < 48; i++ {
+		request.InscriptionDataList = append(request.InscriptionDataList, request.InscriptionDataList[i%2])
+	}
+	tool, err := NewInscriptionTool(network, request)
+	if err != nil {
+		b.Fatal(err)
+	}
+	// a single reveal tx spending the 50 commit outputs
+	batchTx := wire.NewMsgTx(DefaultTxVersion)
+	for _, revealTx := range tool.RevealTx {
+		batchTx.AddTxIn(revealTx.TxIn[0])
+		batchTx.AddTxOut(revealTx.TxOut[0])
+	}
+	tool.RevealTx = []*wire.MsgTx{batchTx}
+	b.Run("Shared", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, _, err := tool.revealSigHashes(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("PerInput", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := range batchTx.TxIn {
+				txSigHashes := txscript.NewTxSigHashes(batchTx, tool.RevealTxPrevOutputFetcher)
+				if _, err := txscript.CalcTapscriptSignaturehash(txSigHashes, txscript.SigHashDefault, batchTx, j,
+					tool.RevealTxPrevOutputFetcher, txscript.NewBaseTapLeaf(tool.InscriptionTxCtxDataList[j].InscriptionScript)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func TestInscriptionBuilder_CommitTotals(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+
+	totalInput := int64(0)
+	for _, prevOutput := range request.CommitTxPrevOutputList {
+		totalInput += prevOutput.Amount
+	}
+	require.Equal(t, totalInput, tool.CommitTotalInput())
+	commitTxFee, _ := tool.CalculateFee()
+	require.Equal(t, tool.CommitTotalInput()-commitTxFee, tool.CommitTotalOutput())
+}
+
+func TestInscribe_ChangePkScript(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	changePkScript, err := hex.DecodeString("00145c005c5532ce810ddf20f9d1d939631b47089ecd")
+	require.NoError(t, err)
+	request.ChangeAddress = ""
+	request.ChangePkScript = changePkScript
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Len(t, tool.CommitTx.TxOut, len(request.InscriptionDataList)+1)
+	require.Equal(t, changePkScript, tool.CommitTx.TxOut[len(request.InscriptionDataList)].PkScript)
+
+	_, err = InscribeForMPCUnsigned(request, network, nil, nil)
+	require.NoError(t, err)
+
+	request.ChangePkScript = []byte{txscript.OP_TRUE}
+	_, err = NewInscriptionTool(network, request)
+	require.ErrorIs(t, err, ErrInvalidChangeAddress)
+	require.EqualError(t, err, "invalid change address: change pk script is not a standard script")
+}
+
+func TestEnvelopeSize(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	for i, data := range request.InscriptionDataList {
+		require.Equal(t, len(tool.InscriptionTxCtxDataList[i].InscriptionScript), EnvelopeSize(data))
+	}
+
+	contentType := "text/plain;charset=utf-8"
+	emptySize := EnvelopeSize(InscriptionData{ContentType: contentType})
+	tests := []struct {
+		bodySize     int
+		envelopeSize int
+	}{
+		{0, emptySize},
+		{75, emptySize + 1 + 75},
+		{76, emptySize + 2 + 76},
+		{520, emptySize + 3 + 520},
+		{521, emptySize + 3 + 520 + 1 + 1},
+		{1040, emptySize + 2*(3+520)},
+	}
+	for _, test := range tests {
+		data := InscriptionData{ContentType: contentType, Body: bytes.Repeat([]byte{0x61}, test.bodySize)}
+		require.Equal(t, test.envelopeSize, EnvelopeSize(data), "body size %d", test.bodySize)
+	}
+}
+
+func TestInscriptionBuilder_SimulateAcceptance(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	tool, err := NewInscriptionTool(network, testInscriptionRequest())
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+
+	tool.RevealTx[1].TxOut[0].Value = 100
+	require.EqualError(t, tool.SimulateAcceptance(), "reveal(index 1) tx output 0 value 100 is below the dust threshold 546")
+}
+
+func TestInscriptionBuilder_RevealsByInscriptionID(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	tool, err := NewInscriptionTool(network, testInscriptionRequest())
+	require.NoError(t, err)
+	revealTxs, err := tool.GetRevealTxHexList()
+	require.NoError(t, err)
+
+	reveals, err := tool.RevealsByInscriptionID()
+	require.NoError(t, err)
+	ids := tool.InscriptionIDs()
+	require.Len(t, reveals, len(ids))
+	for i, id := range ids {
+		require.Equal(t, revealTxs[i], reveals[id])
+	}
+}
+
+// brotli is not a dependency of this module, gzip stands in for it: the reveal fee only
+// depends on the size of the body carried by the envelope, not on the codec.
+func TestInscribe_CompressedBodyRevealFee(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	body := bytes.Repeat([]byte(`{"p":"brc-20","op":"mint","tick":"xcvb","amt":"100"}`), 100)
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err := writer.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.Less(t, compressed.Len()*10, len(body))
+
+	request := testInscriptionRequest()
+	request.RevealFeeRate = 10
+	request.InscriptionDataList[0].Body = body
+	request.InscriptionDataList[1].Body = compressed.Bytes()
+	request.InscriptionDataList[1].RevealAddr = request.InscriptionDataList[0].RevealAddr
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	_, revealTxFees := tool.CalculateFee()
+
+	require.Less(t, revealTxFees[1]*5, revealTxFees[0])
+	envelopeSizeDiff := int64(EnvelopeSize(request.InscriptionDataList[0]) - EnvelopeSize(request.InscriptionDataList[1]))
+	require.InDelta(t, envelopeSizeDiff*request.RevealFeeRate/WitnessScaleFactor, revealTxFees[0]-revealTxFees[1], float64(2*request.RevealFeeRate))
+}
+
+func TestInscriptionBuilder_CheckRevealPrevOutputs(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	tool, err := NewInscriptionTool(network, testInscriptionRequest())
+	require.NoError(t, err)
+	require.NoError(t, tool.checkRevealPrevOutputs())
+
+	out := tool.CommitTx.TxOut[1]
+	tool.CommitTx.TxOut[1] = wire.NewTxOut(out.Value-1, out.PkScript)
+	require.EqualError(t, tool.completeRevealTx(context.Background()), fmt.Sprintf("commit tx output 1 (value %d) does not match the prev output of reveal(index 1) (value %d)",
+		tool.CommitTx.TxOut[1].Value, tool.CommitTx.TxOut[1].Value+1))
+}
+
+func TestInscribe_UTF8Warning(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.InscriptionDataList[1].Body = []byte("invalid \xc3\x28 utf-8")
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Equal(t, []string{"inscription(index 1) declares content type text/plain;charset=utf-8 but its body is not valid utf-8"}, tool.Warnings())
+
+	require.Empty(t, utf8Warning(0, InscriptionData{ContentType: "text/plain", Body: []byte("\xc3\x28")}))
+	require.Empty(t, utf8Warning(0, InscriptionData{ContentType: "image/png", Body: []byte("\xc3\x28")}))
+}
+
+func TestInscriptionBuilder_RevealRateDeviation(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.RevealFeeRate = 7
+	request.InscriptionDataList[1].Body = bytes.Repeat([]byte("large"), 1000)
+	for _, mode := range []RoundingMode{RoundUp, RoundDown, RoundNearest} {
+		request.FeeRoundingMode = mode
+		tool, err := NewInscriptionTool(network, request)
+		require.NoError(t, err)
+		deviations := tool.RevealRateDeviation()
+		require.Len(t, deviations, len(request.InscriptionDataList))
+		for _, deviation := range deviations {
+			require.Less(t, math.Abs(deviation), 1.0)
+		}
+	}
+}
+
+func TestInscribe_PreserveInscribedInput(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	inscribed := request.CommitTxPrevOutputList[2]
+	inscribed.HasInscription = true
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+
+	inscribedTxHash, err := chainhash.NewHashFromStr(inscribed.TxId)
+	require.NoError(t, err)
+	inscribedPkScript, err := AddrToPkScript(inscribed.Address, network)
+	require.NoError(t, err)
+	require.Equal(t, *wire.NewOutPoint(inscribedTxHash, inscribed.VOut), tool.CommitTx.TxIn[0].PreviousOutPoint)
+	require.Equal(t, inscribed.Amount, tool.CommitTx.TxOut[0].Value)
+	require.Equal(t, inscribedPkScript, tool.CommitTx.TxOut[0].PkScript)
+	require.Len(t, tool.CommitTx.TxOut, 1+len(request.InscriptionDataList)+1)
+	for i, tx := range tool.RevealTx {
+		require.Equal(t, uint32(1+i), tx.TxIn[0].PreviousOutPoint.Index)
+		require.Equal(t, tool.InscriptionTxCtxDataList[i].CommitTxAddressPkScript, tool.CommitTx.TxOut[1+i].PkScript)
+	}
+	require.NoError(t, tool.SimulateAcceptance())
+
+	res, err := InscribeForMPCUnsigned(request, network, nil, nil)
+	require.NoError(t, err)
+	commitTx, err := NewTxFromHex(res.CommitTx)
+	require.NoError(t, err)
+	require.Equal(t, tool.CommitTx.TxOut[:3], commitTx.TxOut[:3])
+}
+
+func TestMaxBodySizeForBudget(t *testing.T) {
+	postage := int64(546)
+	revealFeeRate := int64(10)
+	for _, budget := range []int64{10000, 50000, 123456} {
+		bodySize := MaxBodySizeForBudget(budget, revealFeeRate, postage)
+		require.Greater(t, bodySize, 0)
+		require.LessOrEqual(t, postage+revealFeeForBodySize(bodySize, revealFeeRate), budget)
+		require.Greater(t, postage+revealFeeForBodySize(bodySize+1, revealFeeRate), budget)
+	}
+	require.Equal(t, 0, MaxBodySizeForBudget(600, revealFeeRate, postage))
+
+	// the estimate matches the reveal fee of a built inscription
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.RevealFeeRate = revealFeeRate
+	request.InscriptionDataList = request.InscriptionDataList[:1]
+	request.InscriptionDataList[0].ContentType = ""
+	request.InscriptionDataList[0].Body = bytes.Repeat([]byte{0x61}, MaxBodySizeForBudget(50000, revealFeeRate, postage))
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	_, revealTxFees := tool.CalculateFee()
+	require.LessOrEqual(t, postage+revealTxFees[0], int64(50000))
+}
+
+func TestInscribeAirdrop(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	recipients := []string{
+		"mouQtmBWDS7JnT65Grj2tPzdSmGKJgRMhE",
+		"tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc",
+		"2NF33rckfiQTiE5Guk5ufUdwms8PgmtnEdc",
+		"tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr",
+		"tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx",
+	}
+	txs, err := InscribeAirdrop(network, request.CommitTxPrevOutputList, request.InscriptionDataList[0], recipients, 600, 2, 2, request.ChangeAddress)
+	require.NoError(t, err)
+	require.Len(t, txs.RevealTxs, len(recipients))
+
+	commitTx, err := NewTxFromHex(txs.CommitTx)
+	require.NoError(t, err)
+	require.Len(t, commitTx.TxOut, len(recipients)+1)
+	for i, revealTxHex := range txs.RevealTxs {
+		revealTx, err := NewTxFromHex(revealTxHex)
+		require.NoError(t, err)
+		require.Equal(t, commitTx.TxHash(), revealTx.TxIn[0].PreviousOutPoint.Hash)
+		require.Equal(t, int64(600), revealTx.TxOut[0].Value)
+		pkScript, err := AddrToPkScript(recipients[i], network)
+		require.NoError(t, err)
+		require.Equal(t, pkScript, revealTx.TxOut[0].PkScript)
+	}
+
+	_, err = InscribeAirdrop(network, request.CommitTxPrevOutputList, request.InscriptionDataList[0], nil, 600, 2, 2, request.ChangeAddress)
+	require.Error(t, err)
+}
+
+func TestValidateFunding(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.CommitTxPrevOutputList[3].Amount = 500
+
+	shortfall, err := ValidateFunding(network, request)
+	require.NoError(t, err)
+	require.Greater(t, shortfall, int64(0))
+
+	request.CommitTxPrevOutputList[3].Amount += shortfall - 1
+	remaining, err := ValidateFunding(network, request)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), remaining)
+
+	request.CommitTxPrevOutputList[3].Amount++
+	remaining, err = ValidateFunding(network, request)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), remaining)
+	_, err = NewInscriptionTool(network, request)
+	require.NoError(t, err)
+}
+
+func TestInscribe_DefaultPostageByType(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.RevealOutValue = 0
+	request.InscriptionDataList[1].ContentType = "image/png"
+	request.DefaultPostageByType = map[string]int64{"image/": 1000}
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Equal(t, DefaultRevealOutValue, tool.RevealTx[0].TxOut[0].Value)
+	require.Equal(t, int64(1000), tool.RevealTx[1].TxOut[0].Value)
+
+	request.DefaultPostageByType["image/png"] = 2000
+	tool, err = NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Equal(t, int64(2000), tool.RevealTx[1].TxOut[0].Value)
+
+	request.RevealOutValue = 600
+	tool, err = NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Equal(t, int64(600), tool.RevealTx[0].TxOut[0].Value)
+	require.Equal(t, int64(600), tool.RevealTx[1].TxOut[0].Value)
+}
+
+func TestInscriptionBuilder_CommitOutputAddresses(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.CommitTxPrevOutputList[0].HasInscription = true
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	addrs := tool.CommitOutputAddresses()
+	require.Len(t, addrs, len(tool.CommitTx.TxOut))
+	require.Equal(t, request.CommitTxPrevOutputList[0].Address, addrs[0])
+	require.Equal(t, request.ChangeAddress, addrs[len(addrs)-1])
+
+	seen := make(map[string]bool)
+	for i, ctx := range tool.InscriptionTxCtxDataList {
+		require.Equal(t, tool.CommitAddrs[i], addrs[ctx.CommitTxOutIndex])
+		require.Equal(t, ctx.CommitTxOutIndex, tool.RevealTx[i].TxIn[0].PreviousOutPoint.Index)
+		require.False(t, seen[tool.CommitAddrs[i]])
+		seen[tool.CommitAddrs[i]] = true
+	}
+}
+
+func TestInscribe_Metadata(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	// a CBOR byte string of 597 bytes, split over two tag 5 pushes
+	metadata := append([]byte{0x59, 0x02, 0x55}, bytes.Repeat([]byte{0xab}, 597)...)
+	request.InscriptionDataList[0].Metadata = metadata
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+
+	var gotMetadata, gotBody []byte
+	var pushes [][]byte
+	tokenizer := txscript.MakeScriptTokenizer(0, tool.RevealTx[0].TxIn[0].Witness[1])
+	for tokenizer.Next() {
+		pushes = append(pushes, append([]byte{tokenizer.Opcode()}, tokenizer.Data()...))
+	}
+	require.NoError(t, tokenizer.Err())
+	// pubkey, OP_CHECKSIG, OP_FALSE, OP_IF, ord, tag 1, content type, then the metadata tags
+	i := 7
+	for ; pushes[i][0] == txscript.OP_DATA_1 && pushes[i][1] == byte(TagMetadata); i += 2 {
+		gotMetadata = append(gotMetadata, pushes[i+1][1:]...)
+	}
+	require.Equal(t, byte(txscript.OP_0), pushes[i][0])
+	for _, push := range pushes[i+1 : len(pushes)-1] {
+		gotBody = append(gotBody, push[1:]...)
+	}
+	require.Equal(t, metadata, gotMetadata)
+	require.Equal(t, request.InscriptionDataList[0].Body, gotBody)
+	require.Equal(t, byte(txscript.OP_ENDIF), pushes[len(pushes)-1][0])
+}
+
+func TestInscribe_Parent(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	parentTxId := "b61b0172d95e266c18aea0c624db987e971a5d6d4ebc2aaed85da4642d635735"
+	request.InscriptionDataList[0].ParentInscriptionId = parentTxId + "i1"
+	request.InscriptionDataList[0].ParentPrevOutput = &PrevOutput{
+		TxId:       "8a2b1c4f1d8a7c3a9d55c1a4a3a4b1e1d2f6a7e0c8b9d3f2e1a0b9c8d7e6f5a4",
+		VOut:       2,
+		Amount:     546,
+		Address:    "tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc",
+		PrivateKey: "cPnvkvUYyHcSSS26iD1dkrJdV7k1RoUqJLhn3CYxpo398PdLVE22",
+	}
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+
+	revealTx := tool.RevealTx[0]
+	require.Len(t, revealTx.TxIn, 2)
+	require.Len(t, revealTx.TxOut, 2)
+	require.Equal(t, request.InscriptionDataList[0].ParentPrevOutput.TxId, revealTx.TxIn[0].PreviousOutPoint.Hash.String())
+	require.Equal(t, uint32(2), revealTx.TxIn[0].PreviousOutPoint.Index)
+	require.Equal(t, tool.CommitTx.TxHash(), revealTx.TxIn[1].PreviousOutPoint.Hash)
+	// the parent output takes exactly the parent input so the fee does not shift either inscription
+	require.Equal(t, int64(546), revealTx.TxOut[0].Value)
+	require.Equal(t, int64(546), revealTx.TxOut[1].Value)
+	require.Equal(t, revealTx.TxOut[0].PkScript, revealTx.TxOut[1].PkScript)
+
+	parentHash, err := chainhash.NewHashFromStr(parentTxId)
+	require.NoError(t, err)
+	expected := append(append([]byte{txscript.OP_DATA_1, byte(TagParent), 36}, parentHash[:]...), 1, 0, 0, 0)
+	require.True(t, bytes.Contains(revealTx.TxIn[1].Witness[1], expected))
+
+	request.InscriptionDataList[0].ParentPrevOutput = nil
+	_, err = NewInscriptionTool(network, request)
+	require.Error(t, err)
+}
+
+func TestInscribe_KeyTweak(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	untweaked, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+
+	tweak := sha256.Sum256([]byte("collection-1"))
+	request.InscriptionDataList[0].KeyTweak = tweak[:]
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+
+	var tweakScalar btcec.ModNScalar
+	tweakScalar.SetBytes(&tweak)
+	key := untweaked.InscriptionTxCtxDataList[0].PrivateKey.Key
+	key.Add(&tweakScalar)
+	expectedPubKey := schnorr.SerializePubKey(btcec.PrivKeyFromScalar(&key).PubKey())
+
+	ctx := tool.InscriptionTxCtxDataList[0]
+	require.Equal(t, expectedPubKey, schnorr.SerializePubKey(ctx.PrivateKey.PubKey()))
+	require.Equal(t, expectedPubKey, tool.RevealTx[0].TxIn[0].Witness[1][1:33])
+	require.NotEqual(t, untweaked.CommitAddrs[0], tool.CommitAddrs[0])
+	require.Equal(t, untweaked.CommitAddrs[1], tool.CommitAddrs[1])
+
+	request.InscriptionDataList[0].KeyTweak = tweak[:31]
+	_, err = NewInscriptionTool(network, request)
+	require.Error(t, err)
+}
+
+func TestInscriptionBuilder_DepositInstructions(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.InscriptionDataList = append(request.InscriptionDataList, request.InscriptionDataList[0])
+	request.InscriptionDataList[2].Body = []byte(`{"p":"brc-20","op":"mint","tick":"xcvb","amt":"1"}`)
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	commitTxFee, _ := tool.CalculateFee()
+	instructions := tool.DepositInstructions()
+	require.Len(t, instructions, 3)
+
+	total := int64(0)
+	for i, instruction := range instructions {
+		require.Equal(t, tool.CommitAddrs[i], instruction.Address)
+		require.GreaterOrEqual(t, instruction.AmountSats, tool.InscriptionTxCtxDataList[i].RevealTxPrevOutput.Value+commitTxFee/3)
+		total += instruction.AmountSats
+	}
+	totalRevealPrevOutput := int64(0)
+	for _, ctx := range tool.InscriptionTxCtxDataList {
+		totalRevealPrevOutput += ctx.RevealTxPrevOutput.Value
+	}
+	require.Equal(t, totalRevealPrevOutput+commitTxFee, total)
+}
+
+func TestParseInscription(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.InscriptionDataList[0].Body = bytes.Repeat([]byte("0123456789"), 120)
+	request.InscriptionDataList[0].Metadata = []byte{0xa1, 0x64, 0x6e, 0x61, 0x6d, 0x65, 0x61, 0x78}
+
+	txs, err := Inscribe(network, request)
+	require.NoError(t, err)
+	for i, revealTxHex := range txs.RevealTxs {
+		dataList, err := ParseInscription(revealTxHex, network)
+		require.NoError(t, err)
+		require.Len(t, dataList, 1)
+		expected := request.InscriptionDataList[i]
+		require.Equal(t, expected.ContentType, dataList[0].ContentType)
+		require.Equal(t, expected.Body, dataList[0].Body)
+		require.Equal(t, expected.Metadata, dataList[0].Metadata)
+		require.Equal(t, expected.RevealAddr, dataList[0].RevealAddr)
+	}
+
+	envelope := func(prefix string, withBody bool) []byte {
+		builder := txscript.NewScriptBuilder().
+			AddOp(txscript.OP_FALSE).
+			AddOp(txscript.OP_IF).
+			AddData([]byte(prefix)).
+			AddOp(txscript.OP_DATA_1).
+			AddOp(byte(TagContentType)).
+			AddData([]byte("text/plain"))
+		if withBody {
+			builder.AddOp(txscript.OP_0).AddData([]byte("hello"))
+		}
+		script, err := builder.AddOp(txscript.OP_ENDIF).Script()
+		require.NoError(t, err)
+		return script
+	}
+	revealTx := wire.NewMsgTx(DefaultTxVersion)
+	revealTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	script := append(envelope("xyz", true), envelope("ord", false)...)
+	script = append(script, envelope("ord", true)...)
+	revealTx.TxIn[0].Witness = wire.TxWitness{make([]byte, 64), script, make([]byte, 33)}
+	revealTxHex, err := GetTxHex(revealTx)
+	require.NoError(t, err)
+	dataList, err := ParseInscription(revealTxHex, network)
+	require.NoError(t, err)
+	require.Len(t, dataList, 2)
+	require.Equal(t, "text/plain", dataList[0].ContentType)
+	require.Empty(t, dataList[0].Body)
+	require.Equal(t, []byte("hello"), dataList[1].Body)
+
+	revealTx.TxIn[0].Witness[1] = envelope("xyz", true)
+	revealTxHex, err = GetTxHex(revealTx)
+	require.NoError(t, err)
+	_, err = ParseInscription(revealTxHex, network)
+	require.Error(t, err)
+}
+
+func TestInscribe_ChangeBelowScriptDust(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.MinChangeValue = 1
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	change := tool.CommitTx.TxOut[len(tool.CommitTx.TxOut)-1]
+	dust := GetDustThreshold(change.PkScript)
+	require.Greater(t, dust, request.MinChangeValue)
+
+	// change exactly at the script dust threshold is kept
+	request.CommitTxPrevOutputList[3].Amount -= change.Value - dust
+	tool, err = NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Len(t, tool.CommitTx.TxOut, len(request.InscriptionDataList)+1)
+	require.Equal(t, dust, tool.CommitTx.TxOut[len(tool.CommitTx.TxOut)-1].Value)
+
+	// one satoshi less is above MinChangeValue but dust, so it is paid to the fee
+	request.CommitTxPrevOutputList[3].Amount--
+	tool, err = NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Len(t, tool.CommitTx.TxOut, len(request.InscriptionDataList))
+	commitTxFee, _ := tool.CalculateFee()
+	require.Equal(t, tool.CommitTotalInput()-tool.CommitTotalOutput(), commitTxFee)
+}
+
+func TestInscribe_RevealPrivateKeyPerInscription(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	altKeyBytes, err := hex.DecodeString("1790962db820729606cd7b255ace1ac5ebb129ac8e9b2d8534d022194ab25b37")
+	require.NoError(t, err)
+	altKey, _ := btcec.PrivKeyFromBytes(altKeyBytes)
+	commitKey, err := btcutil.DecodeWIF(request.CommitTxPrevOutputList[0].PrivateKey)
+	require.NoError(t, err)
+	request.InscriptionDataList[0].RevealPrivateKey = hex.EncodeToString(altKeyBytes)
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+	require.Equal(t, schnorr.SerializePubKey(altKey.PubKey()), tool.RevealTx[0].TxIn[0].Witness[1][1:33])
+	require.Equal(t, schnorr.SerializePubKey(commitKey.PrivKey.PubKey()), tool.RevealTx[1].TxIn[0].Witness[1][1:33])
+
+	// the key of the inscription takes precedence over the key of the request
+	request.RevealInternalKey = request.CommitTxPrevOutputList[0].PrivateKey
+	request.InscriptionDataList[1].RevealPrivateKey = hex.EncodeToString(altKeyBytes)
+	tool, err = NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Equal(t, schnorr.SerializePubKey(altKey.PubKey()), tool.RevealTx[1].TxIn[0].Witness[1][1:33])
+
+	request.InscriptionDataList[1].RevealPrivateKey = "not a key"
+	_, err = NewInscriptionTool(network, request)
+	require.Error(t, err)
+}
+
+func TestInscriptionBuilder_PackagePSBT(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	tool, err := NewInscriptionTool(network, testInscriptionRequest())
+	require.NoError(t, err)
+
+	bundle, err := tool.PackagePSBT()
+	require.NoError(t, err)
+	commitPSBT, revealPSBTs, err := ParsePackagePSBT(bundle)
+	require.NoError(t, err)
+
+	require.Equal(t, len(tool.CommitTx.TxIn), len(commitPSBT.UnsignedTx.TxIn))
+	require.Equal(t, tool.CommitTx.TxOut, commitPSBT.UnsignedTx.TxOut)
+	for i, in := range commitPSBT.UnsignedTx.TxIn {
+		require.Equal(t, tool.CommitTx.TxIn[i].PreviousOutPoint, in.PreviousOutPoint)
+		require.Equal(t, tool.CommitTxPrevOutputFetcher.FetchPrevOutput(in.PreviousOutPoint), commitPSBT.Inputs[i].WitnessUtxo)
+	}
+	require.NotEmpty(t, commitPSBT.Inputs[0].RedeemScript)
+
+	require.Len(t, revealPSBTs, len(tool.RevealTx))
+	for i, revealPSBT := range revealPSBTs {
+		ctx := tool.InscriptionTxCtxDataList[i]
+		require.Equal(t, tool.CommitTx.TxHash(), revealPSBT.UnsignedTx.TxIn[0].PreviousOutPoint.Hash)
+		require.Equal(t, tool.RevealTx[i].TxOut, revealPSBT.UnsignedTx.TxOut)
+		input := revealPSBT.Inputs[0]
+		require.Equal(t, ctx.RevealTxPrevOutput, input.WitnessUtxo)
+		require.Len(t, input.TaprootLeafScript, 1)
+		require.Equal(t, ctx.InscriptionScript, input.TaprootLeafScript[0].Script)
+		require.Equal(t, ctx.ControlBlockWitness, input.TaprootLeafScript[0].ControlBlock)
+		require.Equal(t, schnorr.SerializePubKey(ctx.PrivateKey.PubKey()), input.TaprootInternalKey)
+	}
+
+	_, _, err = ParsePackagePSBT(`{"commitPsbt":"00"}`)
+	require.Error(t, err)
+}
+
+func TestInscribe_SentinelErrors(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+
+	request := testInscriptionRequest()
+	request.CommitTxPrevOutputList[3].Amount = 500
+	_, err := NewInscriptionTool(network, request)
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+	txs, err := Inscribe(network, request)
+	require.NoError(t, err)
+	require.Empty(t, txs.CommitTx)
+	_, err = InscribeAndBroadcast(network, request, nil)
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+
+	request = testInscriptionRequest()
+	request.ChangeAddress = "not an address"
+	_, err = NewInscriptionTool(network, request)
+	require.ErrorIs(t, err, ErrInvalidChangeAddress)
+
+	request = testInscriptionRequest()
+	request.InscriptionDataList = request.InscriptionDataList[:1]
+	request.InscriptionDataList[0].Body = bytes.Repeat([]byte{'a'}, MaxStandardTxWeight)
+	_, err = NewInscriptionTool(network, request)
+	require.ErrorIs(t, err, ErrRevealWeightExceeded)
+}
+
+func TestInscribe_BurnRevealOutput(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	burnPkScript, err := txscript.NullDataScript([]byte("burn"))
+	require.NoError(t, err)
+	request.InscriptionDataList[0].RevealPkScript = burnPkScript
+
+	_, err = NewInscriptionTool(network, request)
+	require.EqualError(t, err, "reveal(index 0) output is an OP_RETURN script which burns the inscription, set Burn to burn it")
+	for _, prevOutput := range request.CommitTxPrevOutputList {
+		prevOutput.PublicKey = "0357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f"
+	}
+	_, err = InscribeForMPCUnsigned(request, network, nil, nil)
+	require.ErrorContains(t, err, "set Burn to burn it")
+
+	request.InscriptionDataList[0].Burn = true
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Equal(t, burnPkScript, tool.RevealTx[0].TxOut[0].PkScript)
+}
+
+func TestInscribe_DisableRBF(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	for _, disableRBF := range []bool{false, true} {
+		request := testInscriptionRequest()
+		request.DisableRBF = disableRBF
+		expected := uint32(DefaultSequenceNum)
+		if disableRBF {
+			expected = wire.MaxTxInSequenceNum
+		}
+
+		tool, err := NewInscriptionTool(network, request)
+		require.NoError(t, err)
+		for _, in := range tool.CommitTx.TxIn {
+			require.Equal(t, expected, in.Sequence)
+		}
+		for _, revealTx := range tool.RevealTx {
+			for _, in := range revealTx.TxIn {
+				require.Equal(t, expected, in.Sequence)
+			}
+		}
+
+		for _, prevOutput := range request.CommitTxPrevOutputList {
+			prevOutput.PublicKey = "0357bbb2d4a9cb8a2357633f201b9c518c2795ded682b7913c6beef3fe23bd6d2f"
+		}
+		res, err := InscribeForMPCUnsigned(request, network, nil, nil)
+		require.NoError(t, err)
+		commitTx, err := NewTxFromHex(res.CommitTx)
+		require.NoError(t, err)
+		for _, in := range commitTx.TxIn {
+			require.Equal(t, expected, in.Sequence)
+		}
+		for _, revealTxHex := range res.RevealTxs {
+			revealTx, err := NewTxFromHex(revealTxHex)
+			require.NoError(t, err)
+			require.Equal(t, expected, revealTx.TxIn[0].Sequence)
+		}
+	}
+}
+
+func TestEstimateInscribeFees(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := buildTestInscription(t, network)
+	txs, err := Inscribe(network, request)
+	require.NoError(t, err)
+	require.NotEmpty(t, txs.CommitTx)
+
+	commitFee, revealFees, total, err := EstimateInscribeFees(network, request)
+	require.NoError(t, err)
+	require.InDelta(t, txs.CommitTxFee, commitFee, 1)
+	require.Len(t, revealFees, 1)
+	require.InDelta(t, txs.RevealTxFees[0], revealFees[0], 1)
+	require.Equal(t, commitFee+revealFees[0], total)
+
+	// neither the txid nor the key of the funding input is needed
+	request.CommitTxPrevOutputList = []*PrevOutput{{Address: request.CommitTxPrevOutputList[0].Address}}
+	unfundedCommitFee, unfundedRevealFees, _, err := EstimateInscribeFees(network, request)
+	require.NoError(t, err)
+	require.Equal(t, commitFee, unfundedCommitFee)
+	require.Equal(t, revealFees, unfundedRevealFees)
+
+	request.CommitTxPrevOutputList = nil
+	noInputCommitFee, _, _, err := EstimateInscribeFees(network, request)
+	require.NoError(t, err)
+	require.Equal(t, commitFee, noInputCommitFee)
+}
+
+func TestInscriptionBuilder_CostPerByte(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	original := bytes.Repeat([]byte("the same line of text, over and over\n"), 50)
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err := writer.Write(original)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	request.InscriptionDataList[0].Body = original
+	request.InscriptionDataList[1].Body = compressed.Bytes()
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	costs := tool.CostPerByte()
+	require.Len(t, costs, 2)
+	instructions := tool.DepositInstructions()
+	for i, cost := range costs {
+		require.InDelta(t, float64(instructions[i].AmountSats)/float64(len(request.InscriptionDataList[i].Body)), cost, 1e-9)
+	}
+	plainCostPerOriginalByte := costs[0]
+	compressedCostPerOriginalByte := costs[1] * float64(compressed.Len()) / float64(len(original))
+	require.Less(t, compressedCostPerOriginalByte, plainCostPerOriginalByte)
+}
+
+func TestInscribe_ExtraWitness(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	baseline, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+
+	extraWitness := [][]byte{{0x01, 0x02}, bytes.Repeat([]byte{0xee}, 100)}
+	request.InscriptionDataList[0].ExtraWitness = extraWitness
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+
+	witness := tool.RevealTx[0].TxIn[0].Witness
+	require.Len(t, witness, 5)
+	require.Equal(t, tool.InscriptionTxCtxDataList[0].InscriptionScript, witness[1])
+	require.Equal(t, tool.InscriptionTxCtxDataList[0].ControlBlockWitness, witness[2])
+	require.Equal(t, extraWitness, [][]byte(witness[3:]))
+	require.Len(t, tool.RevealTx[1].TxIn[0].Witness, 3)
+
+	// the extra elements weigh 1 + 2 + 1 + 100 witness bytes, paid at 2 sat/vB
+	require.Equal(t, baseline.MustRevealTxFees[0]+52, tool.MustRevealTxFees[0])
+	require.Equal(t, baseline.MustRevealTxFees[1], tool.MustRevealTxFees[1])
+}
+
+func TestInscribe_SingleRevealTx(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.InscriptionDataList = append(request.InscriptionDataList, InscriptionData{
+		ContentType: "text/plain;charset=utf-8",
+		Body:        []byte("third"),
+		RevealAddr:  "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr",
+	})
+	request.SingleRevealTx = true
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+
+	require.Len(t, tool.RevealTx, 1)
+	require.Len(t, tool.MustRevealTxFees, 1)
+	revealTx := tool.RevealTx[0]
+	require.Len(t, revealTx.TxIn, 3)
+	require.Len(t, revealTx.TxOut, 3)
+	require.Len(t, tool.CommitAddrs, 3)
+	for i, ctx := range tool.InscriptionTxCtxDataList {
+		require.Equal(t, tool.CommitTx.TxHash(), revealTx.TxIn[i].PreviousOutPoint.Hash)
+		require.Equal(t, ctx.CommitTxOutIndex, revealTx.TxIn[i].PreviousOutPoint.Index)
+		require.Equal(t, ctx.InscriptionScript, revealTx.TxIn[i].Witness[1])
+		require.Equal(t, int64(546), revealTx.TxOut[i].Value)
+	}
+	// the fee comes from the last commit output only, so every inscription keeps the first sat
+	// of its own output
+	require.Equal(t, int64(546), tool.InscriptionTxCtxDataList[0].RevealTxPrevOutput.Value)
+	require.Equal(t, int64(546), tool.InscriptionTxCtxDataList[1].RevealTxPrevOutput.Value)
+	require.Equal(t, 546+tool.MustRevealTxFees[0], tool.InscriptionTxCtxDataList[2].RevealTxPrevOutput.Value)
+
+	ids := tool.InscriptionIDs()
+	txId := revealTx.TxHash().String()
+	require.Equal(t, []string{txId + "i0", txId + "i1", txId + "i2"}, ids)
+
+	request.InscriptionDataList[0].Body = bytes.Repeat([]byte{'a'}, MaxStandardTxWeight/2)
+	request.InscriptionDataList[1].Body = bytes.Repeat([]byte{'b'}, MaxStandardTxWeight/2)
+	_, err = NewInscriptionTool(network, request)
+	require.ErrorIs(t, err, ErrRevealWeightExceeded)
+}
+
+func TestInscribe_ContentEncoding(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	body := bytes.Repeat([]byte(`{"p":"brc-20","op":"mint","tick":"xcvb","amt":"100"}`), 40)
+	request.InscriptionDataList[0].Body = body
+	request.InscriptionDataList[0].ContentEncoding = "gzip"
+	request.InscriptionDataList[0].CompressBody = true
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+	expected := append([]byte{txscript.OP_DATA_1, byte(TagContentEncoding), 4}, "gzip"...)
+	require.True(t, bytes.Contains(tool.InscriptionTxCtxDataList[0].InscriptionScript, expected))
+	require.False(t, bytes.Contains(tool.InscriptionTxCtxDataList[1].InscriptionScript, expected))
+
+	revealTxHex, err := GetTxHex(tool.RevealTx[0])
+	require.NoError(t, err)
+	dataList, err := ParseInscription(revealTxHex, network)
+	require.NoError(t, err)
+	require.Len(t, dataList, 1)
+	require.Equal(t, "gzip", dataList[0].ContentEncoding)
+	require.Less(t, len(dataList[0].Body), len(body))
+	reader, err := gzip.NewReader(bytes.NewReader(dataList[0].Body))
+	require.NoError(t, err)
+	var decompressed bytes.Buffer
+	_, err = decompressed.ReadFrom(reader)
+	require.NoError(t, err)
+	require.Equal(t, body, decompressed.Bytes())
+
+	request.InscriptionDataList[0].ContentEncoding = "br"
+	_, err = NewInscriptionTool(network, request)
+	require.Error(t, err)
+}
+
+func TestPrevOutputsFromTx(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	privateKey := "cPnvkvUYyHcSSS26iD1dkrJdV7k1RoUqJLhn3CYxpo398PdLVE22"
+	myAddrs := map[string]string{
+		"tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc":                     privateKey,
+		"tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr": privateKey,
+	}
+	tx := wire.NewMsgTx(DefaultTxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil, nil))
+	for _, out := range []struct {
+		addr  string
+		value int64
+	}{
+		{"tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr", 10000},
+		{"mouQtmBWDS7JnT65Grj2tPzdSmGKJgRMhE", 20000},
+		{"tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc", 30000},
+	} {
+		pkScript, err := AddrToPkScript(out.addr, network)
+		require.NoError(t, err)
+		tx.AddTxOut(wire.NewTxOut(out.value, pkScript))
+	}
+	txHex, err := GetTxHex(tx)
+	require.NoError(t, err)
+
+	prevOutputs, err := PrevOutputsFromTx(txHex, myAddrs, network)
+	require.NoError(t, err)
+	txId := tx.TxHash().String()
+	require.Equal(t, []*PrevOutput{
+		{TxId: txId, VOut: 0, Amount: 10000, Address: "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr", PrivateKey: privateKey},
+		{TxId: txId, VOut: 2, Amount: 30000, Address: "tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc", PrivateKey: privateKey},
+	}, prevOutputs)
+
+	_, err = PrevOutputsFromTx(txHex, map[string]string{"2NF33rckfiQTiE5Guk5ufUdwms8PgmtnEdc": privateKey}, network)
+	require.Error(t, err)
+}
+
+func TestInscribe_SelectInputs(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	privateKey := "cPnvkvUYyHcSSS26iD1dkrJdV7k1RoUqJLhn3CYxpo398PdLVE22"
+	taprootAddr := "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr"
+
+	// overshoot with change: the largest input alone covers the target
+	request := testInscriptionRequest()
+	request.SelectInputs = true
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+	require.Len(t, tool.CommitTx.TxIn, 1)
+	require.Equal(t, request.CommitTxPrevOutputList[3].TxId, tool.CommitTx.TxIn[0].PreviousOutPoint.Hash.String())
+	require.Len(t, tool.CommitTxPrivateKeyList, 1)
+	require.Len(t, tool.CommitTx.TxOut, 3)
+	change := tool.CommitTx.TxOut[2].Value
+	require.Equal(t, request.CommitTxPrevOutputList[3].Amount, tool.TargetAmount()+change)
+
+	// exact match: an input worth exactly the target without change is spent alone
+	exactRequest := testInscriptionRequest()
+	exactRequest.CommitTxPrevOutputList = []*PrevOutput{{
+		TxId:       "aa09fa48dda0e2b7de1843c3db8d3f2d7f2cbe0f83331a125b06516a348abd26",
+		VOut:       4,
+		Amount:     1000,
+		Address:    taprootAddr,
+		PrivateKey: privateKey,
+	}}
+	shortfall, err := ValidateFunding(network, exactRequest)
+	require.NoError(t, err)
+	require.Greater(t, shortfall, int64(0))
+	exactRequest.CommitTxPrevOutputList[0].Amount += shortfall
+	exactRequest.CommitTxPrevOutputList = append(exactRequest.CommitTxPrevOutputList, &PrevOutput{
+		TxId:       "22c8a4869f2aa9ee5994959c0978106130290cda53f6e933a8dda2dcb82508d4",
+		VOut:       0,
+		Amount:     546,
+		Address:    "tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc",
+		PrivateKey: privateKey,
+	})
+	exactRequest.SelectInputs = true
+	tool, err = NewInscriptionTool(network, exactRequest)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+	require.Len(t, tool.CommitTx.TxIn, 1)
+	require.Len(t, tool.CommitTx.TxOut, 2)
+	require.Equal(t, exactRequest.CommitTxPrevOutputList[0].Amount, tool.TargetAmount())
+
+	// insufficient funds: every input together does not cover the target
+	request = testInscriptionRequest()
+	request.CommitTxPrevOutputList = request.CommitTxPrevOutputList[:3]
+	request.SelectInputs = true
+	_, err = NewInscriptionTool(network, request)
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+}
+
+func TestCalculateCommitAddresses(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	revealKey := "1790962db820729606cd7b255ace1ac5ebb129ac8e9b2d8534d022194ab25b37"
+	request.RevealInternalKey = revealKey
+
+	commitAddrs, err := CalculateCommitAddresses(network, request.InscriptionDataList, revealKey)
+	require.NoError(t, err)
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Len(t, commitAddrs, len(tool.InscriptionTxCtxDataList))
+	for i, ctx := range tool.InscriptionTxCtxDataList {
+		require.Equal(t, ctx.CommitTxAddress, commitAddrs[i])
+	}
+	require.Equal(t, tool.CommitAddrs, commitAddrs)
+
+	_, err = CalculateCommitAddresses(network, request.InscriptionDataList, "")
+	require.Error(t, err)
+}
+
+func TestInscribe_Pointer(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	for _, c := range []struct {
+		pointer  uint64
+		expected []byte
+	}{
+		{0, []byte{txscript.OP_DATA_1, byte(TagPointer), txscript.OP_0}},
+		{1, []byte{txscript.OP_DATA_1, byte(TagPointer), txscript.OP_DATA_1, 0x01}},
+		{256, []byte{txscript.OP_DATA_1, byte(TagPointer), txscript.OP_DATA_2, 0x00, 0x01}},
+	} {
+		request := testInscriptionRequest()
+		pointer := c.pointer
+		request.InscriptionDataList[0].Pointer = &pointer
+
+		tool, err := NewInscriptionTool(network, request)
+		require.NoError(t, err)
+		require.True(t, bytes.Contains(tool.InscriptionTxCtxDataList[0].InscriptionScript, c.expected))
+
+		revealTxHex, err := GetTxHex(tool.RevealTx[0])
+		require.NoError(t, err)
+		dataList, err := ParseInscription(revealTxHex, network)
+		require.NoError(t, err)
+		require.NotNil(t, dataList[0].Pointer)
+		require.Equal(t, c.pointer, *dataList[0].Pointer)
+		require.Equal(t, request.InscriptionDataList[0].Body, dataList[0].Body)
+
+		revealTxHex, err = GetTxHex(tool.RevealTx[1])
+		require.NoError(t, err)
+		dataList, err = ParseInscription(revealTxHex, network)
+		require.NoError(t, err)
+		require.Nil(t, dataList[0].Pointer)
+	}
+}
+
+func TestInscribe_AddressNetworkMismatch(t *testing.T) {
+	mainnetAddr := "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"
+
+	// testnet request revealing to a mainnet address
+	request := testInscriptionRequest()
+	request.InscriptionDataList[1].RevealAddr = mainnetAddr
+	_, err := NewInscriptionTool(&chaincfg.TestNet3Params, request)
+	require.EqualError(t, err, "reveal address "+mainnetAddr+" of inscription(index 1) is not a testnet3 address")
+
+	// testnet request with a mainnet change address
+	request = testInscriptionRequest()
+	request.ChangeAddress = mainnetAddr
+	_, err = NewInscriptionTool(&chaincfg.TestNet3Params, request)
+	require.ErrorIs(t, err, ErrInvalidChangeAddress)
+	require.ErrorContains(t, err, mainnetAddr)
+
+	// testnet addresses on regtest, whose legacy addresses are the same as testnet ones but
+	// whose segwit addresses are not
+	request = testInscriptionRequest()
+	_, err = NewInscriptionTool(&chaincfg.RegressionNetParams, request)
+	require.EqualError(t, err, "address tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc of commit input 1 is not a regtest address")
+
+	_, err = NewInscriptionTool(&chaincfg.RegressionNetParams, buildTestInscription(t, &chaincfg.RegressionNetParams))
+	require.NoError(t, err)
+}
+
+func TestInscriptionBuilder_GetCommitTxPSBT(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	tool, err := NewInscriptionTool(network, testInscriptionRequest())
+	require.NoError(t, err)
+
+	commitPSBTBase64, err := tool.GetCommitTxPSBT()
+	require.NoError(t, err)
+	commitPSBT, err := psbt.NewFromRawBytes(strings.NewReader(commitPSBTBase64), true)
+	require.NoError(t, err)
+
+	require.Len(t, commitPSBT.UnsignedTx.TxIn, len(tool.CommitTx.TxIn))
+	require.Equal(t, tool.CommitTx.TxOut, commitPSBT.UnsignedTx.TxOut)
+	for i, in := range commitPSBT.UnsignedTx.TxIn {
+		require.Equal(t, tool.CommitTx.TxIn[i].PreviousOutPoint, in.PreviousOutPoint)
+		require.Empty(t, in.SignatureScript)
+		require.Empty(t, in.Witness)
+		require.Equal(t, tool.CommitTxPrevOutputFetcher.FetchPrevOutput(in.PreviousOutPoint), commitPSBT.Inputs[i].WitnessUtxo)
+	}
+
+	_, err = (&InscriptionBuilder{}).GetCommitTxPSBT()
+	require.Error(t, err)
+}
+
+func TestInscribe_PerInscriptionRevealOutValue(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.RevealOutValue = 600
+	request.InscriptionDataList[1].RevealOutValue = 10000
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+	require.Equal(t, int64(600), tool.RevealTx[0].TxOut[0].Value)
+	require.Equal(t, int64(10000), tool.RevealTx[1].TxOut[0].Value)
+	require.Equal(t, 10000+tool.MustRevealTxFees[1], tool.InscriptionTxCtxDataList[1].RevealTxPrevOutput.Value)
+}
+
+func TestInscriptionBuilder_CalculateFee_MultiInputReveal(t *testing.T) {
+	builder := &InscriptionBuilder{
+		CommitTx:                  wire.NewMsgTx(DefaultTxVersion),
+		CommitTxPrevOutputFetcher: txscript.NewMultiPrevOutFetcher(nil),
+		RevealTxPrevOutputFetcher: txscript.NewMultiPrevOutFetcher(nil),
+	}
+	revealTx := wire.NewMsgTx(DefaultTxVersion)
+	for i, value := range []int64{546, 2000} {
+		outPoint := wire.OutPoint{Hash: chainhash.HashH([]byte("commit")), Index: uint32(i)}
+		revealTx.AddTxIn(wire.NewTxIn(&outPoint, nil, nil))
+		builder.RevealTxPrevOutputFetcher.AddPrevOut(outPoint, wire.NewTxOut(value, nil))
+	}
+	revealTx.AddTxOut(wire.NewTxOut(1000, nil))
+	builder.RevealTx = []*wire.MsgTx{revealTx}
+
+	commitTxFee, revealTxFees := builder.CalculateFee()
+	require.Equal(t, int64(0), commitTxFee)
+	require.Equal(t, []int64{546 + 2000 - 1000}, revealTxFees)
+}
+
+func TestInscribe_CommitSigHashType(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	hashType := txscript.SigHashAll | txscript.SigHashAnyOneCanPay
+	request.CommitSigHashType = hashType
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+
+	for i, in := range tool.CommitTx.TxIn {
+		prevOut := tool.CommitTxPrevOutputFetcher.FetchPrevOutput(in.PreviousOutPoint)
+		var signature []byte
+		if txscript.IsPayToPubKeyHash(prevOut.PkScript) {
+			pushes, err := txscript.PushedData(in.SignatureScript)
+			require.NoError(t, err)
+			signature = pushes[0]
+		} else {
+			signature = in.Witness[0]
+		}
+		require.Equal(t, byte(hashType), signature[len(signature)-1], "commit input %d", i)
+
+		vm, err := txscript.NewEngine(prevOut.PkScript, tool.CommitTx, i, txscript.StandardVerifyFlags, nil,
+			txscript.NewTxSigHashes(tool.CommitTx, tool.CommitTxPrevOutputFetcher), prevOut.Value, tool.CommitTxPrevOutputFetcher)
+		require.NoError(t, err)
+		require.NoError(t, vm.Execute())
+	}
+
+	// anyone can pay signatures stay valid once another input is added
+	bumped := tool.CommitTx.Copy()
+	extra := wire.OutPoint{Hash: chainhash.HashH([]byte("fee bump")), Index: 0}
+	bumped.AddTxIn(wire.NewTxIn(&extra, nil, nil))
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for _, in := range tool.CommitTx.TxIn {
+		fetcher.AddPrevOut(in.PreviousOutPoint, tool.CommitTxPrevOutputFetcher.FetchPrevOutput(in.PreviousOutPoint))
+	}
+	fetcher.AddPrevOut(extra, wire.NewTxOut(10000, tool.CommitTxPrevOutputFetcher.FetchPrevOutput(tool.CommitTx.TxIn[3].PreviousOutPoint).PkScript))
+	txSigHashes := txscript.NewTxSigHashes(bumped, fetcher)
+	for i := range tool.CommitTx.TxIn {
+		prevOut := fetcher.FetchPrevOutput(bumped.TxIn[i].PreviousOutPoint)
+		vm, err := txscript.NewEngine(prevOut.PkScript, bumped, i, txscript.StandardVerifyFlags, nil, txSigHashes, prevOut.Value, fetcher)
+		require.NoError(t, err)
+		require.NoError(t, vm.Execute(), "commit input %d", i)
+	}
+}
+
+func TestInscribe_TapMerkleRootCommitInput(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	wif, err := btcutil.DecodeWIF(request.CommitTxPrevOutputList[3].PrivateKey)
+	require.NoError(t, err)
+	leafScript, err := txscript.NewScriptBuilder().AddInt64(144).AddOp(txscript.OP_CHECKSEQUENCEVERIFY).Script()
+	require.NoError(t, err)
+	leafHash := txscript.NewBaseTapLeaf(leafScript).TapHash()
+	outputKey := txscript.ComputeTaprootOutputKey(wif.PrivKey.PubKey(), leafHash[:])
+	addr, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), network)
+	require.NoError(t, err)
+
+	request.CommitTxPrevOutputList[3].Address = addr.EncodeAddress()
+	_, err = NewInscriptionTool(network, request)
+	require.ErrorContains(t, err, "does not match its address")
+
+	request.CommitTxPrevOutputList[3].TapMerkleRoot = leafHash[:]
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+	require.Len(t, tool.CommitTx.TxIn[3].Witness, 1)
+	require.Len(t, tool.CommitTx.TxIn[3].Witness[0], 64)
+
+	request.CommitTxPrevOutputList[3].TapMerkleRoot = leafHash[:31]
+	_, err = NewInscriptionTool(network, request)
+	require.Error(t, err)
+}
+
+func TestInscriptionBuilder_GetRevealSigHashes(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	revealKey := "1790962db820729606cd7b255ace1ac5ebb129ac8e9b2d8534d022194ab25b37"
+	request.RevealInternalKey = revealKey
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	signedReveals := make([]*wire.MsgTx, len(tool.RevealTx))
+	for i, tx := range tool.RevealTx {
+		signedReveals[i] = tx.Copy()
+		tx.TxIn[0].Witness = nil
+	}
+
+	sigHashes, err := tool.GetRevealSigHashes()
+	require.NoError(t, err)
+	require.Len(t, sigHashes, len(tool.RevealTx))
+
+	// sign outside of the builder, as a hardware signer holding the reveal key would
+	keyBytes, err := hex.DecodeString(revealKey)
+	require.NoError(t, err)
+	externalKey, _ := btcec.PrivKeyFromBytes(keyBytes)
+	sigs := make([]string, len(sigHashes))
+	for i, sigHash := range sigHashes {
+		hash, err := hex.DecodeString(sigHash)
+		require.NoError(t, err)
+		signature, err := schnorr.Sign(externalKey, hash)
+		require.NoError(t, err)
+		sigs[i] = hex.EncodeToString(signature.Serialize())
+	}
+	require.NoError(t, tool.SetRevealSignatures(sigs))
+	require.NoError(t, tool.SimulateAcceptance())
+	for i, tx := range tool.RevealTx {
+		require.Equal(t, signedReveals[i].TxIn[0].Witness[1:], tx.TxIn[0].Witness[1:])
+	}
+
+	require.Error(t, tool.SetRevealSignatures(sigs[1:]))
+	sigs[0] = "zz"
+	require.Error(t, tool.SetRevealSignatures(sigs))
+}
+
+func TestInscribe_AggregateReveal(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.InscriptionDataList = append(request.InscriptionDataList, InscriptionData{
+		ContentType: "text/plain;charset=utf-8",
+		Body:        []byte("third"),
+		RevealAddr:  "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr",
+	})
+	request.AggregateReveal = true
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+
+	// one commit output carrying all the postage plus the change
+	require.Len(t, tool.CommitTx.TxOut, 2)
+	require.Len(t, tool.RevealTx, 1)
+	revealTx := tool.RevealTx[0]
+	require.Len(t, revealTx.TxIn, 1)
+	require.Len(t, revealTx.TxOut, 3)
+	commitOut := tool.CommitTx.TxOut[revealTx.TxIn[0].PreviousOutPoint.Index]
+	require.Equal(t, tool.InscriptionTxCtxDataList[0].CommitTxAddressPkScript, commitOut.PkScript)
+	for _, ctx := range tool.InscriptionTxCtxDataList {
+		require.Equal(t, commitOut, ctx.RevealTxPrevOutput)
+		require.Equal(t, ctx.InscriptionScript, revealTx.TxIn[0].Witness[1])
+		require.Equal(t, ctx.ControlBlockWitness, revealTx.TxIn[0].Witness[2])
+	}
+
+	// the reveal fee is all that the single input does not pay to the outputs, at the rate of
+	// the aggregated weight
+	postage := int64(0)
+	for _, out := range revealTx.TxOut {
+		postage += out.Value
+	}
+	require.Len(t, tool.MustRevealTxFees, 1)
+	require.Equal(t, commitOut.Value-postage, tool.MustRevealTxFees[0])
+	weight := GetTransactionWeight(btcutil.NewTx(revealTx))
+	require.Equal(t, computeFee(weight, 2, request.FeeRoundingMode), tool.MustRevealTxFees[0])
+
+	// one signature and control block for all inscriptions makes it lighter than spending a
+	// commit output per inscription in a single reveal tx
+	request.AggregateReveal = false
+	request.SingleRevealTx = true
+	single, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Less(t, weight, GetTransactionWeight(btcutil.NewTx(single.RevealTx[0])))
+
+	// every inscription after the first points to the first sat of its own output
+	revealTxHex, err := GetTxHex(revealTx)
+	require.NoError(t, err)
+	dataList, err := ParseInscription(revealTxHex, network)
+	require.NoError(t, err)
+	require.Len(t, dataList, 3)
+	require.Nil(t, dataList[0].Pointer)
+	require.Equal(t, uint64(revealTx.TxOut[0].Value), *dataList[1].Pointer)
+	require.Equal(t, uint64(revealTx.TxOut[0].Value+revealTx.TxOut[1].Value), *dataList[2].Pointer)
+
+	txId := revealTx.TxHash().String()
+	require.Equal(t, []string{txId + "i0", txId + "i1", txId + "i2"}, tool.InscriptionIDs())
+
+	request.SingleRevealTx = false
+	request.AggregateReveal = true
+	pointer := uint64(1)
+	request.InscriptionDataList[1].Pointer = &pointer
+	_, err = NewInscriptionTool(network, request)
+	require.Error(t, err)
+}
+
+// cancelAfterContext is canceled once its Err has been checked checks times.
+type cancelAfterContext struct {
+	context.Context
+	checks int
+}
+
+func (c *cancelAfterContext) Err() error {
+	if c.checks <= 0 {
+		return context.Canceled
+	}
+	c.checks--
+	return nil
+}
+
+func TestInscribeContext_Canceled(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	for i := 0; i < 8; i++ {
+		request.InscriptionDataList = append(request.InscriptionDataList, request.InscriptionDataList[0])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := InscribeContext(ctx, network, request)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// canceled midway through building the inscriptions
+	_, err = InscribeContext(&cancelAfterContext{Context: context.Background(), checks: 5}, network, request)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// canceled midway through signing the reveals
+	_, err = InscribeContext(&cancelAfterContext{Context: context.Background(), checks: len(request.InscriptionDataList) + 3}, network, request)
+	require.ErrorIs(t, err, context.Canceled)
+
+	txs, err := InscribeContext(context.Background(), network, request)
+	require.NoError(t, err)
+	require.Len(t, txs.RevealTxs, len(request.InscriptionDataList))
+}
+
+func TestInscribe_RevealOutValueDustByScriptType(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	tests := []struct {
+		address string
+		dust    int64
+	}{
+		{"mouQtmBWDS7JnT65Grj2tPzdSmGKJgRMhE", 546},
+		{"tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc", 294},
+		{"tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr", 330},
+	}
+	for _, test := range tests {
+		request := testInscriptionRequest()
+		for i := range request.InscriptionDataList {
+			request.InscriptionDataList[i].RevealAddr = test.address
+		}
+
+		request.RevealOutValue = test.dust
+		tool, err := NewInscriptionTool(network, request)
+		require.NoError(t, err, test.address)
+		require.NoError(t, tool.SimulateAcceptance(), test.address)
+		require.Equal(t, test.dust, tool.RevealTx[0].TxOut[0].Value, test.address)
+
+		request.RevealOutValue = test.dust - 1
+		_, err = NewInscriptionTool(network, request)
+		require.Error(t, err, test.address)
+		require.Contains(t, err.Error(), "below the dust threshold", test.address)
+
+		request.AllowDust = true
+		tool, err = NewInscriptionTool(network, request)
+		require.NoError(t, err, test.address)
+		require.Equal(t, test.dust-1, tool.RevealTx[0].TxOut[0].Value, test.address)
+		require.Error(t, tool.SimulateAcceptance(), test.address)
+	}
+}
+
+func TestInscriptionBuilder_ExportImport(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	wif := request.CommitTxPrevOutputList[0].PrivateKey
+
+	unsigned, err := NewUnsignedInscriptionTool(network, request)
+	require.NoError(t, err)
+	for _, in := range unsigned.CommitTx.TxIn {
+		require.Nil(t, in.Witness)
+		require.Nil(t, in.SignatureScript)
+	}
+	data, err := unsigned.Export()
+	require.NoError(t, err)
+	require.False(t, strings.Contains(string(data), wif))
+
+	imported, err := ImportInscriptionBuilder(data)
+	require.NoError(t, err)
+	require.Equal(t, network, imported.Network)
+	require.Equal(t, unsigned.CommitAddrs, imported.CommitAddrs)
+	require.Equal(t, unsigned.MustRevealTxFees, imported.MustRevealTxFees)
+	for i, ctx := range imported.InscriptionTxCtxDataList {
+		require.Nil(t, ctx.PrivateKey)
+		require.Equal(t, unsigned.InscriptionTxCtxDataList[i].InscriptionScript, ctx.InscriptionScript)
+	}
+
+	privateKeyWif, err := btcutil.DecodeWIF(wif)
+	require.NoError(t, err)
+	key := privateKeyWif.PrivKey
+	commitKeys := []*btcec.PrivateKey{key, key, key, key}
+	otherKey, _ := btcec.NewPrivateKey()
+	require.Error(t, imported.SetPrivateKeys(commitKeys, []*btcec.PrivateKey{key, otherKey}, nil))
+	require.Error(t, imported.SetPrivateKeys(commitKeys[:3], []*btcec.PrivateKey{key, key}, nil))
+	require.NoError(t, imported.SetPrivateKeys(commitKeys, []*btcec.PrivateKey{key, key}, nil))
+	require.NoError(t, imported.CompleteSigning())
+	require.NoError(t, imported.SimulateAcceptance())
+
+	// signing on the importing host gives the txs of signing on the building host
+	signed, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	commitTxHex, err := imported.GetCommitTxHex()
+	require.NoError(t, err)
+	expectedCommitTxHex, err := signed.GetCommitTxHex()
+	require.NoError(t, err)
+	require.Equal(t, expectedCommitTxHex, commitTxHex)
+	revealTxHexes, err := imported.GetRevealTxHexList()
+	require.NoError(t, err)
+	expectedRevealTxHexes, err := signed.GetRevealTxHexList()
+	require.NoError(t, err)
+	require.Equal(t, expectedRevealTxHexes, revealTxHexes)
+	require.Equal(t, signed.InscriptionIDs(), imported.InscriptionIDs())
+}
+
+func TestInscribe_Deterministic(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.Deterministic = true
+
+	first, err := Inscribe(network, request)
+	require.NoError(t, err)
+	second, err := Inscribe(network, request)
+	require.NoError(t, err)
+	require.Equal(t, first.RevealTxs, second.RevealTxs)
+	require.Equal(t, first.RevealTxIds, second.RevealTxIds)
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+
+	// the zero aux rand nonces are not the RFC6979 ones used by default
+	request.Deterministic = false
+	rfc6979, err := Inscribe(network, request)
+	require.NoError(t, err)
+	require.Equal(t, first.RevealTxIds, rfc6979.RevealTxIds)
+	require.NotEqual(t, first.RevealTxs, rfc6979.RevealTxs)
+}
+
+func TestInscribe_CostBreakdown(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	// the inscribed input is sent back as it is and is not part of the breakdown
+	request.CommitTxPrevOutputList[0].HasInscription = true
+
+	txs, err := Inscribe(network, request)
+	require.NoError(t, err)
+	require.Equal(t, int64(546+546+1142196), txs.TotalInputValue)
+	require.Equal(t, int64(2*546), txs.TotalPostage)
+	commitTx, err := NewTxFromHex(txs.CommitTx)
+	require.NoError(t, err)
+	require.Equal(t, commitTx.TxOut[len(commitTx.TxOut)-1].Value, txs.ChangeValue)
+
+	total := txs.TotalPostage + txs.CommitTxFee + txs.ChangeValue
+	for _, fee := range txs.RevealTxFees {
+		total += fee
+	}
+	require.Equal(t, txs.TotalInputValue, total)
+}
+
+func TestInscribe_CommitOpReturn(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	plain, err := Inscribe(network, request)
+	require.NoError(t, err)
+
+	request.CommitOpReturn = []byte("inscribed with go-wallet-sdk")
+	txs, err := Inscribe(network, request)
+	require.NoError(t, err)
+	commitTx, err := NewTxFromHex(txs.CommitTx)
+	require.NoError(t, err)
+	require.Len(t, commitTx.TxOut, 4)
+	expectedScript, err := txscript.NullDataScript(request.CommitOpReturn)
+	require.NoError(t, err)
+	opReturn := commitTx.TxOut[2]
+	require.Equal(t, expectedScript, opReturn.PkScript)
+	require.Equal(t, int64(0), opReturn.Value)
+	require.Equal(t, txs.ChangeValue, commitTx.TxOut[3].Value)
+
+	// the op return output is paid by the commit fee and the change
+	require.Greater(t, txs.CommitTxFee, plain.CommitTxFee)
+	require.Equal(t, plain.CommitTxFee+plain.ChangeValue, txs.CommitTxFee+txs.ChangeValue)
+	total := txs.TotalPostage + txs.CommitTxFee + txs.ChangeValue
+	for _, fee := range txs.RevealTxFees {
+		total += fee
+	}
+	require.Equal(t, txs.TotalInputValue, total)
+
+	request.CommitOpReturn = bytes.Repeat([]byte{1}, 81)
+	_, err = Inscribe(network, request)
+	require.EqualError(t, err, "commit op return data of 81 bytes is longer than 80 bytes")
+}
+
+func TestInscribe_ChangeOutputs(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	p2tr := "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr"
+	p2wpkh := "tb1qtsq9c4fje6qsmheql8gajwtrrdrs38kdzeersc"
+	p2wpkhScript, err := AddrToPkScript(p2wpkh, network)
+	require.NoError(t, err)
+
+	request := testInscriptionRequest()
+	request.ChangeOutputs = []ChangeOutput{{Address: p2tr, Ratio: 0.7}, {Address: p2wpkh, Ratio: 0.3}}
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+	require.Len(t, tool.CommitTx.TxOut, 4)
+	first, second := tool.CommitTx.TxOut[2], tool.CommitTx.TxOut[3]
+	change := first.Value + second.Value
+	require.Equal(t, change, tool.ChangeValue())
+	require.Equal(t, int64(float64(change)*0.7), first.Value)
+	require.Equal(t, p2wpkhScript, second.PkScript)
+	require.Equal(t, tool.TotalInputValue(), tool.TotalPostage()+tool.CommitTotalInput()-tool.CommitTotalOutput()+change+tool.MustRevealTxFees[0]+tool.MustRevealTxFees[1])
+
+	// the p2wpkh share is below its dust threshold, so all the change goes to the p2tr output
+	plain, err := NewInscriptionTool(network, testInscriptionRequest())
+	require.NoError(t, err)
+	request.ChangeOutputs[1].Ratio = 0.0001
+	tool, err = NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.Len(t, tool.CommitTx.TxOut, 3)
+	require.Equal(t, plain.CommitTx.TxOut[2], tool.CommitTx.TxOut[2])
+
+	request.ChangeOutputs[1].Ratio = 0
+	_, err = NewInscriptionTool(network, request)
+	require.Error(t, err)
+	request.ChangeOutputs[1] = ChangeOutput{Address: "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq", Ratio: 0.3}
+	_, err = NewInscriptionTool(network, request)
+	require.ErrorIs(t, err, ErrInvalidChangeAddress)
+}
+
+func TestInscribe_RevealAnnex(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	annex := []byte{txscript.TaprootAnnexTag, 0x01, 0x02}
+	request.InscriptionDataList[0].RevealAnnex = annex
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+	witness := tool.RevealTx[0].TxIn[0].Witness
+	require.Len(t, witness, 4)
+	require.Equal(t, annex, witness[3])
+	require.Len(t, tool.RevealTx[1].TxIn[0].Witness, 3)
+
+	// the signature commits to the annex
+	sigHashes, err := tool.RevealSigHashes()
+	require.NoError(t, err)
+	revealTx := tool.RevealTx[0]
+	ctx := tool.InscriptionTxCtxDataList[0]
+	txSigHashes := txscript.NewTxSigHashes(revealTx, tool.RevealTxPrevOutputFetcher)
+	withoutAnnex, err := txscript.CalcTapscriptSignaturehash(txSigHashes, txscript.SigHashDefault, revealTx, 0,
+		tool.RevealTxPrevOutputFetcher, txscript.NewBaseTapLeaf(ctx.InscriptionScript))
+	require.NoError(t, err)
+	withAnnex, err := txscript.CalcTapscriptSignaturehash(txSigHashes, txscript.SigHashDefault, revealTx, 0,
+		tool.RevealTxPrevOutputFetcher, txscript.NewBaseTapLeaf(ctx.InscriptionScript), txscript.WithAnnex(annex))
+	require.NoError(t, err)
+	require.NotEqual(t, withoutAnnex, sigHashes[0])
+	require.Equal(t, withAnnex, sigHashes[0])
+
+	revealTxHex, err := GetTxHex(tool.RevealTx[0])
+	require.NoError(t, err)
+	dataList, err := ParseInscription(revealTxHex, network)
+	require.NoError(t, err)
+	require.Equal(t, request.InscriptionDataList[0].Body, dataList[0].Body)
+
+	request.InscriptionDataList[0].RevealAnnex = []byte{0x51}
+	_, err = NewInscriptionTool(network, request)
+	require.Error(t, err)
+}
+
+func TestInscribe_FeeRateRange(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+
+	// a zero rate is the network default, never a free tx
+	request := testInscriptionRequest()
+	request.CommitFeeRate = 0
+	request.RevealFeeRate = 0
+	txs, err := Inscribe(network, request)
+	require.NoError(t, err)
+	require.Greater(t, txs.CommitTxFee, int64(0))
+	for _, fee := range txs.RevealTxFees {
+		require.Greater(t, fee, int64(0))
+	}
+
+	tests := []struct {
+		commitFeeRate int64
+		revealFeeRate int64
+		err           string
+	}{
+		{-1, 2, "fee rate out of range: commit fee rate -1 sat/vB is not between 1 and 10000"},
+		{2, -5, "fee rate out of range: reveal fee rate -5 sat/vB is not between 1 and 10000"},
+		{100000, 2, "fee rate out of range: commit fee rate 100000 sat/vB is not between 1 and 10000"},
+		{2, MaxFeeRate + 1, "fee rate out of range: reveal fee rate 10001 sat/vB is not between 1 and 10000"},
+	}
+	for _, test := range tests {
+		request := testInscriptionRequest()
+		request.CommitFeeRate = test.commitFeeRate
+		request.RevealFeeRate = test.revealFeeRate
+		_, err := NewInscriptionTool(network, request)
+		require.ErrorIs(t, err, ErrFeeRateOutOfRange)
+		require.EqualError(t, err, test.err)
+	}
+
+	request = testInscriptionRequest()
+	request.CommitFeeRate = MaxFeeRate
+	request.RevealFeeRate = MaxFeeRate
+	commitFeeRate, revealFeeRates, err := normalizeFeeRates(network, request)
+	require.NoError(t, err)
+	require.Equal(t, MaxFeeRate, commitFeeRate)
+	require.Equal(t, []int64{MaxFeeRate, MaxFeeRate}, revealFeeRates)
+}
+
+func TestInscribe_ExtraRevealOutputs(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	extraAddr := request.InscriptionDataList[1].RevealAddr
+	request.InscriptionDataList[0].ExtraRevealOutputs = []ExtraRevealOutput{{Address: extraAddr, Value: 1000}}
+
+	txs, err := Inscribe(network, request)
+	require.NoError(t, err)
+	commitTx, err := NewTxFromHex(txs.CommitTx)
+	require.NoError(t, err)
+	revealTx, err := NewTxFromHex(txs.RevealTxs[0])
+	require.NoError(t, err)
+	require.Len(t, revealTx.TxOut, 2)
+	require.Equal(t, int64(546), revealTx.TxOut[0].Value)
+	extraPkScript, err := AddrToPkScript(extraAddr, network)
+	require.NoError(t, err)
+	require.Equal(t, extraPkScript, revealTx.TxOut[1].PkScript)
+	require.Equal(t, int64(1000), revealTx.TxOut[1].Value)
+
+	// the commit output pays the postage, the extra output and the reveal fee
+	revealIn := revealTx.TxIn[0].PreviousOutPoint
+	require.Equal(t, commitTx.TxHash(), revealIn.Hash)
+	require.Equal(t, int64(546+1000)+txs.RevealTxFees[0], commitTx.TxOut[revealIn.Index].Value)
+
+	total := txs.TotalPostage + txs.CommitTxFee + txs.ChangeValue
+	for _, fee := range txs.RevealTxFees {
+		total += fee
+	}
+	require.Equal(t, txs.TotalInputValue, total)
+
+	request.InscriptionDataList[0].ExtraRevealOutputs[0].Value = 100
+	_, err = Inscribe(network, request)
+	require.ErrorContains(t, err, "below the dust threshold")
+}
+
+func TestInscribe_EnvelopePrefix(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	request.EnvelopePrefix = "xyz"
+
+	txs, err := Inscribe(network, request)
+	require.NoError(t, err)
+	for i, revealTxHex := range txs.RevealTxs {
+		dataList, err := ParseInscriptionWithPrefix(revealTxHex, "xyz", network)
+		require.NoError(t, err)
+		require.Len(t, dataList, 1)
+		require.Equal(t, request.InscriptionDataList[i].ContentType, dataList[0].ContentType)
+		require.Equal(t, request.InscriptionDataList[i].Body, dataList[0].Body)
+
+		// ord does not see the envelope
+		_, err = ParseInscription(revealTxHex, network)
+		require.Error(t, err)
+	}
+
+	// the prefix defaults to ord
+	request.EnvelopePrefix = ""
+	plain, err := Inscribe(network, request)
+	require.NoError(t, err)
+	request.EnvelopePrefix = OrdPrefix
+	ord, err := Inscribe(network, request)
+	require.NoError(t, err)
+	require.Equal(t, plain.RevealTxs, ord.RevealTxs)
+}
+
+func TestBuildCPFP(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	txs, err := Inscribe(network, request)
+	require.NoError(t, err)
+	parentTx, err := NewTxFromHex(txs.CommitTx)
+	require.NoError(t, err)
+	changeVout := uint32(len(parentTx.TxOut) - 1)
+	change := parentTx.TxOut[changeVout]
+
+	childHex, err := BuildCPFP(txs.CommitTx, changeVout, request.CommitTxPrevOutputList[0].PrivateKey, request.ChangeAddress, 20, network)
+	require.NoError(t, err)
+	childTx, err := NewTxFromHex(childHex)
+	require.NoError(t, err)
+	require.Len(t, childTx.TxIn, 1)
+	require.Len(t, childTx.TxOut, 1)
+	require.Equal(t, parentTx.TxHash(), childTx.TxIn[0].PreviousOutPoint.Hash)
+	require.Equal(t, changeVout, childTx.TxIn[0].PreviousOutPoint.Index)
+
+	// the child pays the combined package size at the new rate
+	fee := change.Value - childTx.TxOut[0].Value
+	packageWeight := GetTransactionWeight2(parentTx) + GetTransactionWeight2(childTx)
+	require.Equal(t, computeFee(packageWeight, 20, RoundUp), fee)
+
+	prevOutFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	prevOutFetcher.AddPrevOut(childTx.TxIn[0].PreviousOutPoint, change)
+	verifyTxInputs(t, childTx, prevOutFetcher)
+
+	_, err = BuildCPFP(txs.CommitTx, uint32(len(parentTx.TxOut)), request.CommitTxPrevOutputList[0].PrivateKey, request.ChangeAddress, 20, network)
+	require.Error(t, err)
+	_, err = BuildCPFP(txs.CommitTx, changeVout, request.CommitTxPrevOutputList[0].PrivateKey, request.ChangeAddress, MaxFeeRate+1, network)
+	require.ErrorIs(t, err, ErrFeeRateOutOfRange)
+}
+
+func TestInscriptionBuilder_GetRevealTxHexListNotLinked(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	unsigned, err := NewUnsignedInscriptionTool(network, request)
+	require.NoError(t, err)
+	_, err = unsigned.GetRevealTxHexList()
+	require.ErrorIs(t, err, ErrRevealNotLinked)
+
+	// the reveal txs are built before the commit tx fails to be funded
+	request.CommitTxPrevOutputList[3].Amount = 500
+	tool, err := NewInscriptionTool(network, request)
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+	require.NotNil(t, tool)
+	require.NotEmpty(t, tool.RevealTx)
+	_, err = tool.GetRevealTxHexList()
+	require.ErrorIs(t, err, ErrRevealNotLinked)
+
+	require.NoError(t, unsigned.CompleteSigning())
+	revealTxs, err := unsigned.GetRevealTxHexList()
+	require.NoError(t, err)
+	require.Len(t, revealTxs, len(request.InscriptionDataList))
+}
+
+func TestInscribe_MultisigCommitInput(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	pubKeys := make([]*btcutil.AddressPubKey, 3)
+	wifs := make([]string, 3)
+	for i := range pubKeys {
+		key, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{byte(i + 1)}, 32))
+		pubKey, err := btcutil.NewAddressPubKey(key.PubKey().SerializeCompressed(), network)
+		require.NoError(t, err)
+		pubKeys[i] = pubKey
+		wif, err := btcutil.NewWIF(key, network, true)
+		require.NoError(t, err)
+		wifs[i] = wif.String()
+	}
+	redeemScript, err := txscript.MultiSigScript(pubKeys, 2)
+	require.NoError(t, err)
+	scriptHash := sha256.Sum256(redeemScript)
+	address, err := btcutil.NewAddressWitnessScriptHash(scriptHash[:], network)
+	require.NoError(t, err)
+
+	request := testInscriptionRequest()
+	multisigInput := &PrevOutput{
+		TxId:         "5c2ba3c0a4c4b4b36f3df3c87ba1c1d1a5e2c5ae20c0a5d8e7f2e0b0c8f8a1d2",
+		VOut:         1,
+		Amount:       50000,
+		Address:      address.EncodeAddress(),
+		RedeemScript: redeemScript,
+		// the signatures follow the order of the keys in the script, not this one
+		PrivateKeys: []string{wifs[2], wifs[0]},
+	}
+	request.CommitTxPrevOutputList = append(request.CommitTxPrevOutputList, multisigInput)
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	verifyTxInputs(t, tool.CommitTx, tool.CommitTxPrevOutputFetcher)
+	witness := tool.CommitTx.TxIn[len(tool.CommitTx.TxIn)-1].Witness
+	require.Len(t, witness, 4)
+	require.Empty(t, witness[0])
+	require.Equal(t, redeemScript, []byte(witness[3]))
+	require.NoError(t, tool.SimulateAcceptance())
+
+	// the fees are estimated without the keys of the multisig input, within a vbyte since the
+	// ecdsa signatures may differ in length
+	multisigInput.PrivateKeys = nil
+	commitFee, _, _, err := EstimateInscribeFees(network, request)
+	require.NoError(t, err)
+	require.InDelta(t, tool.CommitTotalInput()-tool.CommitTotalOutput(), commitFee, float64(request.CommitFeeRate))
+	multisigInput.PrivateKeys = []string{wifs[2], wifs[0]}
+
+	commitPSBT, err := tool.commitPSBT()
+	require.NoError(t, err)
+	require.Equal(t, redeemScript, commitPSBT.Inputs[len(commitPSBT.Inputs)-1].WitnessScript)
+
+	multisigInput.PrivateKeys = wifs[:1]
+	_, err = NewInscriptionTool(network, request)
+	require.ErrorContains(t, err, "has 1 keys of its 2-of-3 redeem script")
+
+	otherKey, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{9}, 32))
+	otherWif, err := btcutil.NewWIF(otherKey, network, true)
+	require.NoError(t, err)
+	multisigInput.PrivateKeys = []string{wifs[0], otherWif.String()}
+	_, err = NewInscriptionTool(network, request)
+	require.ErrorContains(t, err, "is not a key of its redeem script")
+
+	multisigInput.PrivateKeys = wifs[:2]
+	multisigInput.Address = request.CommitTxPrevOutputList[1].Address
+	_, err = NewInscriptionTool(network, request)
+	require.ErrorContains(t, err, "is not the p2wsh address of its redeem script")
+}
+
+func TestInscribe_DelegateInscriptionId(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	delegateId := "6fb976ab49dcec017f1e201e84395983204ae1a7c2abf7ced0a85d692e442799i0"
+	request := testInscriptionRequest()
+	plain, err := Inscribe(network, request)
+	require.NoError(t, err)
+	request.InscriptionDataList[0].Body = nil
+	request.InscriptionDataList[0].DelegateInscriptionId = delegateId
+	txs, err := Inscribe(network, request)
+	require.NoError(t, err)
+
+	revealTx, err := NewTxFromHex(txs.RevealTxs[0])
+	require.NoError(t, err)
+	delegate, err := inscriptionIdBytes(delegateId)
+	require.NoError(t, err)
+	require.Len(t, delegate, 36)
+	envelope, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_FALSE).
+		AddOp(txscript.OP_IF).
+		AddData([]byte(OrdPrefix)).
+		AddOp(txscript.OP_DATA_1).
+		AddOp(byte(TagDelegate)).
+		AddData(delegate).
+		AddOp(txscript.OP_ENDIF).
+		Script()
+	require.NoError(t, err)
+	script := witnessTapscript(revealTx.TxIn[0].Witness)
+	require.True(t, bytes.HasSuffix(script, envelope))
+	require.Len(t, script, 34+len(envelope))
+
+	dataList, err := ParseInscription(txs.RevealTxs[0], network)
+	require.NoError(t, err)
+	require.Len(t, dataList, 1)
+	require.Equal(t, delegateId, dataList[0].DelegateInscriptionId)
+	require.Empty(t, dataList[0].ContentType)
+	require.Empty(t, dataList[0].Body)
+
+	plainRevealTx, err := NewTxFromHex(plain.RevealTxs[0])
+	require.NoError(t, err)
+	require.Less(t, GetTxVirtualSize2(revealTx), GetTxVirtualSize2(plainRevealTx))
+
+	request.InscriptionDataList[0].Body = []byte("art")
+	_, err = Inscribe(network, request)
+	require.Error(t, err)
+}
+
+func TestInscribe_PreserveFirstSat(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	rareSat := &PrevOutput{
+		TxId:             "0c5d2e9d9b8e4c1f6a7b3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a",
+		VOut:             2,
+		Amount:           10000,
+		Address:          "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr",
+		PrivateKey:       "cPnvkvUYyHcSSS26iD1dkrJdV7k1RoUqJLhn3CYxpo398PdLVE22",
+		PreserveFirstSat: true,
+	}
+	request.CommitTxPrevOutputList = append(request.CommitTxPrevOutputList, rareSat)
+	request.InscriptionDataList[0].RevealOutValue = 10000
+
+	tool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+	require.NoError(t, tool.SimulateAcceptance())
+	// the rare sat is the first sat of the commit tx, of its first output and of the reveal output
+	first := tool.CommitTx.TxIn[0].PreviousOutPoint
+	require.Equal(t, rareSat.TxId, first.Hash.String())
+	require.Equal(t, rareSat.VOut, first.Index)
+	require.Equal(t, uint32(0), tool.InscriptionTxCtxDataList[0].CommitTxOutIndex)
+	revealTx := tool.RevealTx[0]
+	require.Equal(t, uint32(0), revealTx.TxIn[0].PreviousOutPoint.Index)
+	require.Equal(t, int64(10000), revealTx.TxOut[0].Value)
+
+	request.InscriptionDataList[0].RevealOutValue = 546
+	_, err = NewInscriptionTool(network, request)
+	require.ErrorContains(t, err, "postage 546 must equal the value 10000")
+
+	request.InscriptionDataList[0].RevealOutValue = 10000
+	request.CommitTxPrevOutputList[0].HasInscription = true
+	_, err = NewInscriptionTool(network, request)
+	require.Error(t, err)
+}
+
+func TestNewInscriptionToolWithKeys(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := testInscriptionRequest()
+	wifTool, err := NewInscriptionTool(network, request)
+	require.NoError(t, err)
+
+	keys := make([][]byte, len(request.CommitTxPrevOutputList))
+	rawRequest := testInscriptionRequest()
+	for i, prevOutput := range rawRequest.CommitTxPrevOutputList {
+		privateKeyWif, err := btcutil.DecodeWIF(prevOutput.PrivateKey)
+		require.NoError(t, err)
+		keys[i] = privateKeyWif.PrivKey.Serialize()
+		prevOutput.PrivateKey = ""
+	}
+	rawTool, err := NewInscriptionToolWithKeys(network, rawRequest, keys)
+	require.NoError(t, err)
+	for _, prevOutput := range rawRequest.CommitTxPrevOutputList {
+		require.Empty(t, prevOutput.PrivateKey)
+	}
+
+	wifCommitTx, err := wifTool.GetCommitTxHex()
+	require.NoError(t, err)
+	rawCommitTx, err := rawTool.GetCommitTxHex()
+	require.NoError(t, err)
+	require.Equal(t, wifCommitTx, rawCommitTx)
+	wifRevealTxs, err := wifTool.GetRevealTxHexList()
+	require.NoError(t, err)
+	rawRevealTxs, err := rawTool.GetRevealTxHexList()
+	require.NoError(t, err)
+	require.Equal(t, wifRevealTxs, rawRevealTxs)
+
+	_, err = NewInscriptionToolWithKeys(network, rawRequest, keys[:3])
+	require.Error(t, err)
+	keys[0] = keys[0][:31]
+	_, err = NewInscriptionToolWithKeys(network, rawRequest, keys)
+	require.Error(t, err)
+}
@@ -167,6 +167,16 @@ func SignBip0322(message string, address string, privateKey string) (string, err
 	return base64.StdEncoding.EncodeToString(txHexBytes), nil
 }
 
+// SignBIP322 returns the base64 BIP-322 simple signature of message by privateKey for address,
+// proving the control of address, e.g. the commit or change address of an inscription.
+func SignBIP322(privateKey *btcec.PrivateKey, address, message string) (string, error) {
+	wif, err := btcutil.NewWIF(privateKey, &chaincfg.MainNetParams, true)
+	if err != nil {
+		return "", err
+	}
+	return SignBip0322(message, address, wif.String())
+}
+
 func BuildToSpend(message string, address string, network *chaincfg.Params) (string, error) {
 	if network == nil {
 		network = &chaincfg.MainNetParams
@@ -1,7 +1,13 @@
 package bitcoin
 
 import (
+	"bytes"
+	"encoding/base64"
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -18,6 +24,42 @@ func TestSignBip0322TapRoot(t *testing.T) {
 	require.Equal(t, "AUA62WElIGyeXycCIIuyOgB9sn/Y7Jjk0yDfhu83qWCuEO6wib+ScHrpm/GilVZPWnVyI+i3r0RDZ0L3qkEmiCyy", sig)
 }
 
+func TestSignBIP322(t *testing.T) {
+	address := "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr"
+	wif, err := btcutil.DecodeWIF("cPnvkvUYyHcSSS26iD1dkrJdV7k1RoUqJLhn3CYxpo398PdLVE22")
+	require.NoError(t, err)
+	sig, err := SignBIP322(wif.PrivKey, address, "Hello World")
+	require.NoError(t, err)
+	require.Equal(t, "AUA62WElIGyeXycCIIuyOgB9sn/Y7Jjk0yDfhu83qWCuEO6wib+ScHrpm/GilVZPWnVyI+i3r0RDZ0L3qkEmiCyy", sig)
+
+	// verify the witness spends the to_spend output of the message
+	sigBytes, err := base64.StdEncoding.DecodeString(sig)
+	require.NoError(t, err)
+	reader := bytes.NewReader(sigBytes)
+	count, err := wire.ReadVarInt(reader, 0)
+	require.NoError(t, err)
+	witness := make(wire.TxWitness, count)
+	for i := range witness {
+		witness[i], err = wire.ReadVarBytes(reader, 0, txscript.MaxScriptSize, "witness")
+		require.NoError(t, err)
+	}
+	toSpendTxId, err := BuildToSpend("Hello World", address, &chaincfg.MainNetParams)
+	require.NoError(t, err)
+	toSpendTxHash, err := chainhash.NewHashFromStr(toSpendTxId)
+	require.NoError(t, err)
+	pkScript, err := AddrToPkScript(address, &chaincfg.TestNet3Params)
+	require.NoError(t, err)
+	toSign := wire.NewMsgTx(0)
+	in := wire.NewTxIn(wire.NewOutPoint(toSpendTxHash, 0), nil, witness)
+	in.Sequence = 0
+	toSign.AddTxIn(in)
+	toSign.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}))
+	prevOutFetcher := txscript.NewCannedPrevOutputFetcher(pkScript, 0)
+	vm, err := txscript.NewEngine(pkScript, toSign, 0, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(toSign, prevOutFetcher), 0, prevOutFetcher)
+	require.NoError(t, err)
+	require.NoError(t, vm.Execute())
+}
+
 func TestBuildToSpend(t *testing.T) {
 	network := &chaincfg.MainNetParams
 	txId, err := BuildToSpend("Hello World", "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l", network)
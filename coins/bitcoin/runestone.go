@@ -0,0 +1,409 @@
+package bitcoin
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// RuneTag is the tag pushed before a field of a runestone, as defined by the runes protocol.
+type RuneTag uint8
+
+const (
+	RuneTagBody         RuneTag = 0
+	RuneTagDivisibility RuneTag = 1
+	RuneTagFlags        RuneTag = 2
+	RuneTagSpacers      RuneTag = 3
+	RuneTagRune         RuneTag = 4
+	RuneTagSymbol       RuneTag = 5
+	RuneTagPremine      RuneTag = 6
+	RuneTagCap          RuneTag = 8
+	RuneTagAmount       RuneTag = 10
+	RuneTagHeightStart  RuneTag = 12
+	RuneTagHeightEnd    RuneTag = 14
+	RuneTagOffsetStart  RuneTag = 16
+	RuneTagOffsetEnd    RuneTag = 18
+	RuneTagMint         RuneTag = 20
+	RuneTagPointer      RuneTag = 22
+)
+
+const (
+	RuneFlagEtching = 1 << 0
+	RuneFlagTerms   = 1 << 1
+	RuneFlagTurbo   = 1 << 2
+
+	// RunestoneMagicNumber is the opcode following OP_RETURN in a runestone output.
+	RunestoneMagicNumber = txscript.OP_13
+	MaxRuneDivisibility  = 38
+)
+
+// maxRuneValue is the largest rune name value, the protocol encoding it as a u128.
+var maxRuneValue = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+// RuneId identifies a rune by the height of the block and the index in it of its etching tx.
+type RuneId struct {
+	Block uint64 `json:"block"`
+	Tx    uint32 `json:"tx"`
+}
+
+// RuneEdict transfers Amount of the rune Id to the output at index Output. An Output equal to
+// the number of outputs splits Amount between all the outputs but the OP_RETURN ones.
+type RuneEdict struct {
+	Id     RuneId `json:"id"`
+	Amount uint64 `json:"amount"`
+	Output uint32 `json:"output"`
+}
+
+// RuneTerms are the open mint terms of an etched rune. Amount and Cap are omitted from the
+// runestone when 0, which leaves the rune unmintable, the heights and offsets when nil.
+type RuneTerms struct {
+	Amount      uint64  `json:"amount,omitempty"`
+	Cap         uint64  `json:"cap,omitempty"`
+	HeightStart *uint64 `json:"heightStart,omitempty"`
+	HeightEnd   *uint64 `json:"heightEnd,omitempty"`
+	OffsetStart *uint64 `json:"offsetStart,omitempty"`
+	OffsetEnd   *uint64 `json:"offsetEnd,omitempty"`
+}
+
+// RuneEtching creates a new rune. Divisibility, Symbol and Premine are omitted from the
+// runestone when 0, which the protocol reads as no decimals, the ¤ symbol and no premine.
+type RuneEtching struct {
+	// Rune is the name of the rune, letters A to Z optionally separated by • or . spacers, e.g.
+	// UNCOMMON•GOODS. When empty the protocol assigns a reserved name.
+	Rune         string     `json:"rune,omitempty"`
+	Divisibility uint8      `json:"divisibility,omitempty"`
+	Symbol       rune       `json:"symbol,omitempty"`
+	Premine      uint64     `json:"premine,omitempty"`
+	Terms        *RuneTerms `json:"terms,omitempty"`
+	Turbo        bool       `json:"turbo,omitempty"`
+}
+
+// Runestone is the content of a runes protocol OP_RETURN output. Amounts are u128 in the
+// protocol and limited to uint64 here.
+type Runestone struct {
+	Etching *RuneEtching `json:"etching,omitempty"`
+	Mint    *RuneId      `json:"mint,omitempty"`
+	// Pointer is the output receiving the runes left unallocated by the edicts, the first output
+	// which is not an OP_RETURN when nil.
+	Pointer *uint32     `json:"pointer,omitempty"`
+	Edicts  []RuneEdict `json:"edicts,omitempty"`
+}
+
+// EncodeRunestone returns the OP_RETURN OP_13 script carrying the runestone, its payload of
+// LEB128 tag and value pairs being split into pushes of at most 520 bytes.
+func (runestone *Runestone) EncodeRunestone() ([]byte, error) {
+	payload, err := runestone.payload()
+	if err != nil {
+		return nil, err
+	}
+	script := []byte{txscript.OP_RETURN, RunestoneMagicNumber}
+	for len(payload) > 0 {
+		chunk := payload
+		if len(chunk) > txscript.MaxScriptElementSize {
+			chunk = chunk[:txscript.MaxScriptElementSize]
+		}
+		script = appendPushBytes(script, chunk)
+		payload = payload[len(chunk):]
+	}
+	return script, nil
+}
+
+func (runestone *Runestone) payload() ([]byte, error) {
+	var payload []byte
+	appendField := func(tag RuneTag, value uint64) {
+		payload = appendRuneVarint(payload, new(big.Int).SetUint64(uint64(tag)))
+		payload = appendRuneVarint(payload, new(big.Int).SetUint64(value))
+	}
+	if etching := runestone.Etching; etching != nil {
+		if etching.Divisibility > MaxRuneDivisibility {
+			return nil, fmt.Errorf("rune divisibility %d is greater than %d", etching.Divisibility, MaxRuneDivisibility)
+		}
+		if etching.Symbol != 0 && !utf8.ValidRune(etching.Symbol) {
+			return nil, fmt.Errorf("rune symbol %U is not a valid unicode code point", etching.Symbol)
+		}
+		flags := uint64(RuneFlagEtching)
+		if etching.Terms != nil {
+			flags |= RuneFlagTerms
+		}
+		if etching.Turbo {
+			flags |= RuneFlagTurbo
+		}
+		appendField(RuneTagFlags, flags)
+		spacers := uint32(0)
+		if etching.Rune != "" {
+			value, runeSpacers, err := ParseRuneName(etching.Rune)
+			if err != nil {
+				return nil, err
+			}
+			payload = appendRuneVarint(payload, new(big.Int).SetUint64(uint64(RuneTagRune)))
+			payload = appendRuneVarint(payload, value)
+			spacers = runeSpacers
+		}
+		if etching.Divisibility != 0 {
+			appendField(RuneTagDivisibility, uint64(etching.Divisibility))
+		}
+		if spacers != 0 {
+			appendField(RuneTagSpacers, uint64(spacers))
+		}
+		if etching.Symbol != 0 {
+			appendField(RuneTagSymbol, uint64(etching.Symbol))
+		}
+		if etching.Premine != 0 {
+			appendField(RuneTagPremine, etching.Premine)
+		}
+		if terms := etching.Terms; terms != nil {
+			if terms.Amount != 0 {
+				appendField(RuneTagAmount, terms.Amount)
+			}
+			if terms.Cap != 0 {
+				appendField(RuneTagCap, terms.Cap)
+			}
+			for _, field := range []struct {
+				tag   RuneTag
+				value *uint64
+			}{
+				{RuneTagHeightStart, terms.HeightStart},
+				{RuneTagHeightEnd, terms.HeightEnd},
+				{RuneTagOffsetStart, terms.OffsetStart},
+				{RuneTagOffsetEnd, terms.OffsetEnd},
+			} {
+				if field.value != nil {
+					appendField(field.tag, *field.value)
+				}
+			}
+		}
+	}
+	if mint := runestone.Mint; mint != nil {
+		if err := checkRuneId(*mint); err != nil {
+			return nil, err
+		}
+		appendField(RuneTagMint, mint.Block)
+		appendField(RuneTagMint, uint64(mint.Tx))
+	}
+	if runestone.Pointer != nil {
+		appendField(RuneTagPointer, uint64(*runestone.Pointer))
+	}
+	if len(runestone.Edicts) == 0 {
+		return payload, nil
+	}
+
+	// the edicts are sorted by rune id, each id being encoded as a delta from the previous one
+	edicts := make([]RuneEdict, len(runestone.Edicts))
+	copy(edicts, runestone.Edicts)
+	sort.SliceStable(edicts, func(i, j int) bool {
+		if edicts[i].Id.Block != edicts[j].Id.Block {
+			return edicts[i].Id.Block < edicts[j].Id.Block
+		}
+		return edicts[i].Id.Tx < edicts[j].Id.Tx
+	})
+	payload = appendRuneVarint(payload, new(big.Int).SetUint64(uint64(RuneTagBody)))
+	previous := RuneId{}
+	for _, edict := range edicts {
+		if err := checkRuneId(edict.Id); err != nil {
+			return nil, err
+		}
+		blockDelta := edict.Id.Block - previous.Block
+		txDelta := uint64(edict.Id.Tx)
+		if blockDelta == 0 {
+			txDelta -= uint64(previous.Tx)
+		}
+		for _, value := range []uint64{blockDelta, txDelta, edict.Amount, uint64(edict.Output)} {
+			payload = appendRuneVarint(payload, new(big.Int).SetUint64(value))
+		}
+		previous = edict.Id
+	}
+	return payload, nil
+}
+
+// checkRuneId rejects the ids which can not be etched, a tx index in block 0.
+func checkRuneId(id RuneId) error {
+	if id.Block == 0 && id.Tx != 0 {
+		return fmt.Errorf("rune id %d:%d is invalid", id.Block, id.Tx)
+	}
+	return nil
+}
+
+// ParseRuneName returns the value of a rune name of letters A to Z, in which A is 0, Z is 25 and
+// AA is 26, along with the spacers bit field of its • or . separators, bit i being set for a
+// spacer after letter i.
+func ParseRuneName(name string) (*big.Int, uint32, error) {
+	value := new(big.Int)
+	spacers := uint32(0)
+	letters := 0
+	for _, c := range name {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			if letters > 0 {
+				value.Add(value, big.NewInt(1))
+			}
+			value.Mul(value, big.NewInt(26))
+			value.Add(value, big.NewInt(int64(c-'A')))
+			if value.Cmp(maxRuneValue) > 0 {
+				return nil, 0, fmt.Errorf("rune name %s is out of range", name)
+			}
+			letters++
+		case c == '•' || c == '.':
+			if letters == 0 {
+				return nil, 0, fmt.Errorf("rune name %s starts with a spacer", name)
+			}
+			flag := uint32(1) << (letters - 1)
+			if spacers&flag != 0 {
+				return nil, 0, fmt.Errorf("rune name %s has a double spacer", name)
+			}
+			spacers |= flag
+		default:
+			return nil, 0, fmt.Errorf("rune name %s has an invalid character %q", name, c)
+		}
+	}
+	if letters == 0 {
+		return nil, 0, errors.New("rune name is empty")
+	}
+	if spacers>>(letters-1) != 0 {
+		return nil, 0, fmt.Errorf("rune name %s ends with a spacer", name)
+	}
+	return value, spacers, nil
+}
+
+// appendRuneVarint appends value as an unsigned LEB128 integer, seven bits per byte with the
+// high bit set on every byte but the last.
+func appendRuneVarint(payload []byte, value *big.Int) []byte {
+	v := new(big.Int).Set(value)
+	mask := big.NewInt(0x7f)
+	for v.BitLen() > 7 {
+		payload = append(payload, byte(new(big.Int).And(v, mask).Uint64())|0x80)
+		v.Rsh(v, 7)
+	}
+	return append(payload, byte(v.Uint64()))
+}
+
+// appendPushBytes appends a push of data with the smallest push opcode for its length. Unlike
+// txscript.ScriptBuilder.AddData it never turns a one byte push into OP_1 to OP_16, which
+// runestones do not allow.
+func appendPushBytes(script []byte, data []byte) []byte {
+	switch {
+	case len(data) < txscript.OP_PUSHDATA1:
+		script = append(script, byte(len(data)))
+	case len(data) <= 0xff:
+		script = append(script, txscript.OP_PUSHDATA1, byte(len(data)))
+	default:
+		script = append(script, txscript.OP_PUSHDATA2)
+		script = binary.LittleEndian.AppendUint16(script, uint16(len(data)))
+	}
+	return append(script, data...)
+}
+
+type RuneTxRequest struct {
+	PrevOutputList []*PrevOutput `json:"prevOutputList"`
+	Runestone      Runestone     `json:"runestone"`
+	// Outputs come first in the tx, followed by the runestone output and the change output. The
+	// runes of the inputs and the ones minted or premined are sent to the first of them unless
+	// the runestone says otherwise.
+	Outputs       []*TxOutput `json:"outputs"`
+	ChangeAddress string      `json:"changeAddress"`
+	FeeRate       int64       `json:"feeRate"`
+}
+
+// BuildRuneTx returns the signed hex of a tx spending the PrevOutputList of request to its
+// Outputs, the runestone output and the change, which is dropped when below the dust threshold.
+// Etching a named rune also requires a commitment to the name in a taproot input spent by script
+// path at least six blocks after it confirmed, which BuildRuneTx does not build.
+func BuildRuneTx(network *chaincfg.Params, request *RuneTxRequest) (string, error) {
+	if len(request.PrevOutputList) == 0 {
+		return "", errors.New("rune tx has no input")
+	}
+	if request.FeeRate < MinRelayFeeRate || request.FeeRate > MaxFeeRate {
+		return "", fmt.Errorf("%w: rune tx fee rate %d sat/vB is not between %d and %d", ErrFeeRateOutOfRange, request.FeeRate, MinRelayFeeRate, MaxFeeRate)
+	}
+	runestoneScript, err := request.Runestone.EncodeRunestone()
+	if err != nil {
+		return "", err
+	}
+	changePkScript, err := AddrToPkScript(request.ChangeAddress, network)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidChangeAddress, err)
+	}
+
+	tx := wire.NewMsgTx(DefaultTxVersion)
+	prevOutFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	privateKeys := make([]*btcec.PrivateKey, 0, len(request.PrevOutputList))
+	totalInputValue := int64(0)
+	for i, prevOutput := range request.PrevOutputList {
+		txHash, err := chainhash.NewHashFromStr(prevOutput.TxId)
+		if err != nil {
+			return "", err
+		}
+		pkScript, err := AddrToPkScript(prevOutput.Address, network)
+		if err != nil {
+			return "", err
+		}
+		privateKeyWif, err := btcutil.DecodeWIF(prevOutput.PrivateKey)
+		if err != nil {
+			return "", fmt.Errorf("private key of input %d error: %w", i, err)
+		}
+		in := wire.NewTxIn(wire.NewOutPoint(txHash, prevOutput.VOut), nil, nil)
+		in.Sequence = DefaultSequenceNum
+		tx.AddTxIn(in)
+		prevOutFetcher.AddPrevOut(in.PreviousOutPoint, wire.NewTxOut(prevOutput.Amount, pkScript))
+		privateKeys = append(privateKeys, privateKeyWif.PrivKey)
+		totalInputValue += prevOutput.Amount
+	}
+	totalOutputValue := int64(0)
+	for i, output := range request.Outputs {
+		pkScript, err := AddrToPkScript(output.Address, network)
+		if err != nil {
+			return "", err
+		}
+		if dust := GetDustThreshold(pkScript); output.Amount < dust {
+			return "", fmt.Errorf("output %d value %d is below the dust threshold %d of %s", i, output.Amount, dust, output.Address)
+		}
+		tx.AddTxOut(wire.NewTxOut(output.Amount, pkScript))
+		totalOutputValue += output.Amount
+	}
+	tx.AddTxOut(wire.NewTxOut(0, runestoneScript))
+
+	// the fee is estimated on the tx signed with the change output, which is dropped, and its
+	// value left to the miners, when below the dust threshold
+	tx.AddTxOut(wire.NewTxOut(0, changePkScript))
+	if err := Sign(tx, privateKeys, prevOutFetcher); err != nil {
+		return "", err
+	}
+	fee := computeFee(GetTransactionWeight2(tx), request.FeeRate, RoundUp)
+	changeValue := totalInputValue - totalOutputValue - fee
+	if changeValue >= GetDustThreshold(changePkScript) {
+		tx.TxOut[len(tx.TxOut)-1].Value = changeValue
+	} else {
+		tx.TxOut = tx.TxOut[:len(tx.TxOut)-1]
+		if err := Sign(tx, privateKeys, prevOutFetcher); err != nil {
+			return "", err
+		}
+		fee = computeFee(GetTransactionWeight2(tx), request.FeeRate, RoundUp)
+		if totalInputValue-totalOutputValue < fee {
+			return "", fmt.Errorf("%w: inputs of %d can not pay the outputs of %d and the fee %d", ErrInsufficientBalance, totalInputValue, totalOutputValue, fee)
+		}
+	}
+
+	// a pointer or edict beyond the outputs makes a cenotaph, which burns the runes of the inputs
+	outputs := uint32(len(tx.TxOut))
+	if pointer := request.Runestone.Pointer; pointer != nil && *pointer >= outputs {
+		return "", fmt.Errorf("runestone pointer %d is out of range of the %d outputs", *pointer, outputs)
+	}
+	for i, edict := range request.Runestone.Edicts {
+		if edict.Output > outputs {
+			return "", fmt.Errorf("runestone edict %d output %d is out of range of the %d outputs", i, edict.Output, outputs)
+		}
+	}
+	if err := Sign(tx, privateKeys, prevOutFetcher); err != nil {
+		return "", err
+	}
+	return GetTxHex(tx)
+}
@@ -0,0 +1,168 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRuneName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"A":                            "0",
+		"Z":                            "25",
+		"AA":                           "26",
+		"UNCOMMON•GOODS":               "2055900680524219742",
+		"BCGDENLQRQWDSLRUGSNLBTMFIJAV": "340282366920938463463374607431768211455",
+	} {
+		value, _, err := ParseRuneName(name)
+		require.NoError(t, err, name)
+		require.Equal(t, expected, value.String(), name)
+	}
+	_, spacers, err := ParseRuneName("UNCOMMON•GOODS")
+	require.NoError(t, err)
+	require.Equal(t, uint32(1<<7), spacers)
+	_, spacers, err = ParseRuneName("A.B•C")
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), spacers)
+
+	for _, name := range []string{"", "•A", "A•", "A••B", "abc", "BCGDENLQRQWDSLRUGSNLBTMFIJAW"} {
+		_, _, err := ParseRuneName(name)
+		require.Error(t, err, name)
+	}
+}
+
+func TestEncodeRunestone(t *testing.T) {
+	// mint of DOG•GO•TO•THE•MOON, as seen on mainnet
+	runestone := &Runestone{Mint: &RuneId{Block: 840000, Tx: 3}}
+	script, err := runestone.EncodeRunestone()
+	require.NoError(t, err)
+	require.Equal(t, "6a5d0614c0a2331403", hex.EncodeToString(script))
+
+	// the encipher vector of ord, named ABC with spacers instead of its raw rune and spacers
+	heightStart, heightEnd, offsetStart, offsetEnd := uint64(12), uint64(13), uint64(15), uint64(16)
+	pointer := uint32(0)
+	runestone = &Runestone{
+		Etching: &RuneEtching{
+			Rune:         "A•B•C",
+			Divisibility: 7,
+			Symbol:       '@',
+			Premine:      8,
+			Terms: &RuneTerms{
+				Amount:      14,
+				Cap:         11,
+				HeightStart: &heightStart,
+				HeightEnd:   &heightEnd,
+				OffsetStart: &offsetStart,
+				OffsetEnd:   &offsetEnd,
+			},
+			Turbo: true,
+		},
+		Mint:    &RuneId{Block: 17, Tx: 18},
+		Pointer: &pointer,
+		Edicts: []RuneEdict{
+			{Id: RuneId{Block: 5, Tx: 6}, Amount: 4, Output: 1},
+			{Id: RuneId{Block: 2, Tx: 3}, Amount: 1, Output: 0},
+		},
+	}
+	script, err = runestone.EncodeRunestone()
+	require.NoError(t, err)
+	require.Equal(t, "6a5d28"+"020704da0501070303054006080a0e080b0c0c0e0d100f1210141114121600000203010003060401", hex.EncodeToString(script))
+
+	// a transfer, the tx delta of an edict in a new block is not relative
+	runestone = &Runestone{Edicts: []RuneEdict{{Id: RuneId{Block: 840000, Tx: 1}, Amount: 1000, Output: 1}}}
+	script, err = runestone.EncodeRunestone()
+	require.NoError(t, err)
+	require.Equal(t, "6a5d0800c0a23301e80701", hex.EncodeToString(script))
+
+	// the payload is never pushed with OP_1 to OP_16
+	script, err = (&Runestone{Pointer: &pointer}).EncodeRunestone()
+	require.NoError(t, err)
+	require.Equal(t, "6a5d021600", hex.EncodeToString(script))
+
+	// long payloads are split into pushes of at most 520 bytes
+	edicts := make([]RuneEdict, 200)
+	for i := range edicts {
+		edicts[i] = RuneEdict{Id: RuneId{Block: 840000, Tx: uint32(i)}, Amount: 1 << 40, Output: 1}
+	}
+	script, err = (&Runestone{Edicts: edicts}).EncodeRunestone()
+	require.NoError(t, err)
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	var pushes [][]byte
+	for tokenizer.Next() {
+		if tokenizer.Opcode() > txscript.OP_PUSHDATA4 {
+			continue
+		}
+		require.LessOrEqual(t, len(tokenizer.Data()), txscript.MaxScriptElementSize)
+		pushes = append(pushes, tokenizer.Data())
+	}
+	require.NoError(t, tokenizer.Err())
+	require.Greater(t, len(pushes), 1)
+
+	_, err = (&Runestone{Etching: &RuneEtching{Divisibility: 39}}).EncodeRunestone()
+	require.Error(t, err)
+	_, err = (&Runestone{Mint: &RuneId{Block: 0, Tx: 1}}).EncodeRunestone()
+	require.Error(t, err)
+}
+
+func TestAppendRuneVarint(t *testing.T) {
+	maxValue, _ := new(big.Int).SetString("340282366920938463463374607431768211455", 10)
+	for value, expected := range map[string]string{
+		"0":               "00",
+		"127":             "7f",
+		"128":             "8001",
+		"840000":          "c0a233",
+		maxValue.String(): strings.Repeat("ff", 18) + "03",
+	} {
+		v, _ := new(big.Int).SetString(value, 10)
+		require.Equal(t, expected, hex.EncodeToString(appendRuneVarint(nil, v)), value)
+	}
+}
+
+func TestBuildRuneTx(t *testing.T) {
+	network := &chaincfg.TestNet3Params
+	request := &RuneTxRequest{
+		PrevOutputList: []*PrevOutput{{
+			TxId:       "453aa6dd39f31f06cd50b72a8683b8c0402ab36f889d96696317503a025a21b5",
+			VOut:       0,
+			Amount:     100000,
+			Address:    "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr",
+			PrivateKey: "cPnvkvUYyHcSSS26iD1dkrJdV7k1RoUqJLhn3CYxpo398PdLVE22",
+		}},
+		Runestone:     Runestone{Mint: &RuneId{Block: 840000, Tx: 3}},
+		Outputs:       []*TxOutput{{Address: "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr", Amount: 546}},
+		ChangeAddress: "tb1pklh8lqax5l7m2ycypptv2emc4gata2dy28svnwcp9u32wlkenvsspcvhsr",
+		FeeRate:       10,
+	}
+	txHex, err := BuildRuneTx(network, request)
+	require.NoError(t, err)
+	tx, err := NewTxFromHex(txHex)
+	require.NoError(t, err)
+	require.Len(t, tx.TxOut, 3)
+	require.Equal(t, int64(546), tx.TxOut[0].Value)
+	require.Equal(t, "6a5d0614c0a2331403", hex.EncodeToString(tx.TxOut[1].PkScript))
+	require.Equal(t, int64(0), tx.TxOut[1].Value)
+	fee := int64(100000) - 546 - tx.TxOut[2].Value
+	require.Equal(t, computeFee(GetTransactionWeight2(tx), 10, RoundUp), fee)
+
+	prevOutFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	pkScript, err := AddrToPkScript(request.PrevOutputList[0].Address, network)
+	require.NoError(t, err)
+	prevOutFetcher.AddPrevOut(tx.TxIn[0].PreviousOutPoint, wire.NewTxOut(100000, pkScript))
+	verifyTxInputs(t, tx, prevOutFetcher)
+
+	// an edict beyond the outputs would burn the runes
+	request.Runestone = Runestone{Edicts: []RuneEdict{{Id: RuneId{Block: 840000, Tx: 3}, Amount: 1, Output: 4}}}
+	_, err = BuildRuneTx(network, request)
+	require.Error(t, err)
+
+	request.Runestone = Runestone{}
+	request.PrevOutputList[0].Amount = 1000
+	_, err = BuildRuneTx(network, request)
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+}
@@ -34,6 +34,8 @@ type Src20InscriptionTool struct {
 	CommitTx                  *wire.MsgTx
 	MustCommitTxFee           int64
 	CommitAddrs               []string
+	// postageOutputs is the number of commit outputs before the change output
+	postageOutputs int
 }
 
 func NewSrc20InscriptionTool(network *chaincfg.Params, request *Src20InscriptionRequest) (*Src20InscriptionTool, error) {
@@ -169,6 +171,7 @@ func (tool *Src20InscriptionTool) buildCommitTx(commitTxPrevOutputList []*PrevOu
 	if err != nil {
 		return err
 	}
+	tool.postageOutputs = len(tx.TxOut)
 	tx.AddTxOut(wire.NewTxOut(0, changePkScript))
 	txForEstimate := wire.NewMsgTx(DefaultTxVersion)
 	txForEstimate.TxIn = tx.TxIn
@@ -187,7 +190,7 @@ func (tool *Src20InscriptionTool) buildCommitTx(commitTxPrevOutputList []*PrevOu
 		feeWithoutChange := btcutil.Amount(GetTxVirtualSize(btcutil.NewTx(txForEstimate))) * btcutil.Amount(commitFeeRate)
 		if totalSenderAmount-btcutil.Amount(totalRevealPrevOutputValue)-feeWithoutChange < 0 {
 			tool.MustCommitTxFee = int64(btcutil.Amount(totalRevealPrevOutputValue) + fee)
-			return errors.New("insufficient balance")
+			return ErrInsufficientBalance
 		}
 	}
 	tool.CommitTx = tx
@@ -213,9 +216,38 @@ func (tool *Src20InscriptionTool) CalculateFee() (int64, []int64) {
 	return commitTxFee, make([]int64, 0)
 }
 
+// TotalInputValue returns the value of the commit inputs.
+func (tool *Src20InscriptionTool) TotalInputValue() int64 {
+	total := int64(0)
+	for _, prevOutput := range tool.CommitTxPrevOutputList {
+		total += prevOutput.Amount
+	}
+	return total
+}
+
+// TotalPostage returns the value of the commit outputs holding the stamp, to the reveal address
+// and in the data multisig outputs.
+func (tool *Src20InscriptionTool) TotalPostage() int64 {
+	total := int64(0)
+	for _, out := range tool.CommitTx.TxOut[:tool.postageOutputs] {
+		total += out.Value
+	}
+	return total
+}
+
+// ChangeValue returns the value the commit tx sends back to the change address, 0 without
+// a change output.
+func (tool *Src20InscriptionTool) ChangeValue() int64 {
+	total := int64(0)
+	for _, out := range tool.CommitTx.TxOut[tool.postageOutputs:] {
+		total += out.Value
+	}
+	return total
+}
+
 func Src20Inscribe(network *chaincfg.Params, request *Src20InscriptionRequest) (*InscribeTxs, error) {
 	tool, err := NewSrc20InscriptionTool(network, request)
-	if err != nil && err.Error() == "insufficient balance" {
+	if errors.Is(err, ErrInsufficientBalance) {
 		return &InscribeTxs{
 			CommitTx:    "",
 			RevealTxs:   []string{},
@@ -236,10 +268,15 @@ func Src20Inscribe(network *chaincfg.Params, request *Src20InscriptionRequest) (
 	commitTxFee, revealTxFees := tool.CalculateFee()
 
 	return &InscribeTxs{
-		CommitTx:     commitTx,
-		CommitTxFee:  commitTxFee,
-		RevealTxs:    make([]string, 0),
-		RevealTxFees: revealTxFees,
-		CommitAddrs:  tool.CommitAddrs,
+		CommitTx:        commitTx,
+		CommitTxFee:     commitTxFee,
+		RevealTxs:       make([]string, 0),
+		RevealTxFees:    revealTxFees,
+		CommitAddrs:     tool.CommitAddrs,
+		CommitTxId:      tool.CommitTx.TxHash().String(),
+		RevealTxIds:     make([]string, 0),
+		TotalInputValue: tool.TotalInputValue(),
+		TotalPostage:    tool.TotalPostage(),
+		ChangeValue:     tool.ChangeValue(),
 	}, nil
 }